@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestLogEntry records a single matched or unmatched request for later
+// inspection via GET /admin/requests.
+type RequestLogEntry struct {
+	Time     time.Time `json:"time"`
+	Protocol string    `json:"protocol"` // "http" or "tcp"
+	Summary  string    `json:"summary"`  // e.g. "GET /users/42" or "tcp:9001"
+	Matched  bool      `json:"matched"`
+	StubName string    `json:"stub_name,omitempty"`
+	Body     string    `json:"body,omitempty"` // Captured request body, or hex for TCP
+}
+
+// RequestLog is a bounded, thread-safe ring buffer of recent requests.
+type RequestLog struct {
+	mu      sync.Mutex
+	entries []RequestLogEntry
+	max     int
+}
+
+// NewRequestLog creates a RequestLog retaining at most max entries.
+func NewRequestLog(max int) *RequestLog {
+	return &RequestLog{max: max}
+}
+
+// Add appends an entry, dropping the oldest once the log is full.
+func (l *RequestLog) Add(entry RequestLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > l.max {
+		l.entries = l.entries[len(l.entries)-l.max:]
+	}
+}
+
+// All returns a snapshot of the logged entries, most recent last.
+func (l *RequestLog) All() []RequestLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]RequestLogEntry(nil), l.entries...)
+}
+
+// requestLog is the process-wide rolling log surfaced at GET /admin/requests.
+var requestLog = NewRequestLog(200)
+
+// AdminServer exposes a management REST API for mutating stubs at runtime
+// and inspecting recent traffic, so the tool can be driven from CI
+// setup/teardown code the way WireMock is.
+type AdminServer struct {
+	http       *HTTPStubServer
+	tcp        *TCPStubServer
+	grpc       *GRPCStubServer // nil if the process wasn't started with --grpc-protoset
+	configFile string
+}
+
+// NewAdminServer builds an admin API bound to the given stub servers.
+// configFile is re-read on POST /admin/reload; it may be empty if the
+// process was started with hardcoded stubs only. grpcServer may be nil.
+func NewAdminServer(httpServer *HTTPStubServer, tcpServer *TCPStubServer, grpcServer *GRPCStubServer, configFile string) *AdminServer {
+	return &AdminServer{http: httpServer, tcp: tcpServer, grpc: grpcServer, configFile: configFile}
+}
+
+// Handler builds the admin API's http.Handler.
+func (a *AdminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/stubs/http", a.handleHTTPStubs)
+	mux.HandleFunc("/admin/stubs/http/", a.handleHTTPStubs)
+	mux.HandleFunc("/admin/stubs/tcp", a.handleTCPStubs)
+	mux.HandleFunc("/admin/stubs/tcp/", a.handleTCPStubs)
+	mux.HandleFunc("/admin/stubs/ws", a.handleWSStubs)
+	mux.HandleFunc("/admin/stubs/ws/", a.handleWSStubs)
+	mux.HandleFunc("/admin/stubs/grpc", a.handleGRPCStubs)
+	mux.HandleFunc("/admin/stubs/grpc/", a.handleGRPCStubs)
+	mux.HandleFunc("/admin/reload", a.handleReload)
+	mux.HandleFunc("/admin/requests", a.handleRequests)
+	mux.HandleFunc("/admin/scenarios/", a.handleScenarios)
+	return mux
+}
+
+// handleScenarios implements POST /admin/scenarios/{name}/reset.
+func (a *AdminServer) handleScenarios(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/admin/scenarios/")
+	name, action, ok := strings.Cut(path, "/")
+	if !ok || action != "reset" || name == "" {
+		http.Error(w, "expected POST /admin/scenarios/{name}/reset", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scenarios.Reset(name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":    name,
+		"state":   scenarios.State(name),
+		"counter": scenarios.Counter(name),
+	})
+}
+
+// handleHTTPStubs implements GET/POST on /admin/stubs/http and
+// PUT/DELETE on /admin/stubs/http/{name}.
+func (a *AdminServer) handleHTTPStubs(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/admin/stubs/http/")
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.http.Stubs())
+	case http.MethodPost:
+		var stub HTTPStub
+		if !decodeJSONBody(w, r, &stub) {
+			return
+		}
+		a.http.AddStub(stub)
+		writeJSON(w, http.StatusCreated, stub)
+	case http.MethodPut:
+		var stub HTTPStub
+		if !decodeJSONBody(w, r, &stub) {
+			return
+		}
+		a.http.RemoveStub(name)
+		a.http.AddStub(stub)
+		writeJSON(w, http.StatusOK, stub)
+	case http.MethodDelete:
+		if !a.http.RemoveStub(name) {
+			http.Error(w, "stub not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTCPStubs implements GET/POST on /admin/stubs/tcp and
+// PUT/DELETE on /admin/stubs/tcp/{port}.
+func (a *AdminServer) handleTCPStubs(w http.ResponseWriter, r *http.Request) {
+	portStr := strings.TrimPrefix(r.URL.Path, "/admin/stubs/tcp/")
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.tcp.Stubs())
+	case http.MethodPost, http.MethodPut:
+		var stub TCPStub
+		if !decodeJSONBody(w, r, &stub) {
+			return
+		}
+		a.tcp.AddStub(stub)
+		writeJSON(w, http.StatusOK, stub)
+	case http.MethodDelete:
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			http.Error(w, "port must be an integer", http.StatusBadRequest)
+			return
+		}
+		if !a.tcp.RemoveStub(port) {
+			http.Error(w, "stub not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWSStubs implements GET/POST on /admin/stubs/ws and PUT/DELETE on
+// /admin/stubs/ws/{name}.
+func (a *AdminServer) handleWSStubs(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/admin/stubs/ws/")
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.http.WSStubs())
+	case http.MethodPost:
+		var stub WSStub
+		if !decodeJSONBody(w, r, &stub) {
+			return
+		}
+		a.http.AddWSStub(stub)
+		writeJSON(w, http.StatusCreated, stub)
+	case http.MethodPut:
+		var stub WSStub
+		if !decodeJSONBody(w, r, &stub) {
+			return
+		}
+		a.http.RemoveWSStub(name)
+		a.http.AddWSStub(stub)
+		writeJSON(w, http.StatusOK, stub)
+	case http.MethodDelete:
+		if !a.http.RemoveWSStub(name) {
+			http.Error(w, "stub not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGRPCStubs implements GET/POST on /admin/stubs/grpc and DELETE on
+// /admin/stubs/grpc/{name}. Returns 503 if the process wasn't started with
+// --grpc-protoset, since there's no method registry to validate a stub against.
+func (a *AdminServer) handleGRPCStubs(w http.ResponseWriter, r *http.Request) {
+	if a.grpc == nil {
+		http.Error(w, "server was not started with --grpc-protoset", http.StatusServiceUnavailable)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/admin/stubs/grpc/")
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.grpc.Stubs())
+	case http.MethodPost:
+		var stub GRPCStub
+		if !decodeJSONBody(w, r, &stub) {
+			return
+		}
+		a.grpc.AddStub(stub)
+		writeJSON(w, http.StatusCreated, stub)
+	case http.MethodDelete:
+		if !a.grpc.RemoveStub(name) {
+			http.Error(w, "stub not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReload re-reads the process's config file and replaces all stub stores.
+func (a *AdminServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.configFile == "" {
+		http.Error(w, "server was not started with --config, nothing to reload", http.StatusBadRequest)
+		return
+	}
+
+	config, err := LoadConfig(a.configFile)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	a.http.SetStubs(config.HTTPStubs)
+	a.http.SetWSStubs(config.WSStubs)
+	a.reloadTCPStubs(config.TCPStubs)
+
+	if a.grpc != nil {
+		a.grpc.SetStubs(config.GRPCStubs)
+	} else if len(config.GRPCStubs) > 0 {
+		log.Printf("[admin] Config declares %d gRPC stub(s) but the server was not started with --grpc-protoset; ignoring", len(config.GRPCStubs))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{
+		"http_stubs": len(config.HTTPStubs),
+		"tcp_stubs":  len(config.TCPStubs),
+		"ws_stubs":   len(config.WSStubs),
+		"grpc_stubs": len(config.GRPCStubs),
+	})
+}
+
+// reloadTCPStubs diffs the freshly loaded config against the currently
+// running stubs by port: ports no longer present are stopped, unchanged
+// ports are left running, and only added/changed ports bounce their
+// listener. This avoids tearing down every TCP listener on every reload.
+func (a *AdminServer) reloadTCPStubs(stubs []TCPStub) {
+	current := a.tcp.Stubs()
+
+	desired := make(map[int]TCPStub, len(stubs))
+	for _, stub := range stubs {
+		desired[stub.Port] = stub
+	}
+
+	for port := range current {
+		if _, ok := desired[port]; !ok {
+			a.tcp.RemoveStub(port)
+		}
+	}
+
+	for port, stub := range desired {
+		if existing, ok := current[port]; ok {
+			if reflect.DeepEqual(existing, stub) {
+				continue
+			}
+			a.tcp.RemoveStub(port)
+		}
+		a.tcp.AddStub(stub)
+	}
+}
+
+// handleRequests returns the rolling log of matched/unmatched requests.
+func (a *AdminServer) handleRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, requestLog.All())
+}
+
+// decodeJSONBody decodes r's body into v, writing a 400 response and
+// returning false on failure.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeJSON writes v as an indented JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+// fakeListener tracks whether Close was called, so tests can assert a
+// reload left an unaffected port's listener running rather than bouncing it.
+type fakeListener struct{ closed bool }
+
+func (f *fakeListener) Accept() (net.Conn, error) { select {} }
+func (f *fakeListener) Close() error              { f.closed = true; return nil }
+func (f *fakeListener) Addr() net.Addr            { return &net.TCPAddr{} }
+
+func TestRequestLogDropsOldestPastMax(t *testing.T) {
+	log := NewRequestLog(2)
+	log.Add(RequestLogEntry{Summary: "a"})
+	log.Add(RequestLogEntry{Summary: "b"})
+	log.Add(RequestLogEntry{Summary: "c"})
+
+	entries := log.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected log capped at 2 entries, got %d", len(entries))
+	}
+	if entries[0].Summary != "b" || entries[1].Summary != "c" {
+		t.Errorf("expected the oldest entry to be dropped, got %+v", entries)
+	}
+}
+
+func TestReloadTCPStubsReplacesStubSetByPort(t *testing.T) {
+	tcp := NewTCPStubServer()
+	tcp.AddStub(TCPStub{Port: 9001, Name: "old-9001"})
+	tcp.AddStub(TCPStub{Port: 9002, Name: "old-9002"})
+
+	admin := &AdminServer{tcp: tcp}
+	admin.reloadTCPStubs([]TCPStub{
+		{Port: 9001, Name: "new-9001"},
+		{Port: 9003, Name: "new-9003"},
+	})
+
+	stubs := tcp.Stubs()
+	if len(stubs) != 2 {
+		t.Fatalf("expected 2 stubs after reload, got %d", len(stubs))
+	}
+	if stubs[9001].Name != "new-9001" {
+		t.Errorf("expected port 9001 to be replaced, got %+v", stubs[9001])
+	}
+	if _, ok := stubs[9002]; ok {
+		t.Errorf("expected port 9002 to be removed by reload, got %+v", stubs[9002])
+	}
+	if stubs[9003].Name != "new-9003" {
+		t.Errorf("expected port 9003 to be added by reload, got %+v", stubs[9003])
+	}
+}
+
+func TestReloadTCPStubsLeavesUnaffectedPortsRunning(t *testing.T) {
+	tcp := NewTCPStubServer()
+	unaffected := &fakeListener{}
+	changed := &fakeListener{}
+	tcp.stubs[9001] = &TCPStub{Port: 9001, Name: "keep"}
+	tcp.stubs[9002] = &TCPStub{Port: 9002, Name: "old-9002"}
+	tcp.listeners[9001] = unaffected
+	tcp.listeners[9002] = changed
+
+	admin := &AdminServer{tcp: tcp}
+	admin.reloadTCPStubs([]TCPStub{
+		{Port: 9001, Name: "keep"},
+		{Port: 9002, Name: "new-9002"},
+	})
+
+	if unaffected.closed {
+		t.Errorf("expected the listener for an unchanged port to be left running")
+	}
+	if !changed.closed {
+		t.Errorf("expected the listener for a changed port to be bounced")
+	}
+}
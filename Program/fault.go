@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LatencyFault describes a delay sampled from a distribution, replacing a
+// stub's fixed Delay/DelayMs with something closer to real network jitter.
+type LatencyFault struct {
+	Distribution string `yaml:"distribution,omitempty" json:"distribution,omitempty"` // fixed (default) | uniform | normal | lognormal
+	MeanMs       int    `yaml:"mean_ms,omitempty" json:"mean_ms,omitempty"`
+	StddevMs     int    `yaml:"stddev_ms,omitempty" json:"stddev_ms,omitempty"` // normal/lognormal only
+	MinMs        int    `yaml:"min_ms,omitempty" json:"min_ms,omitempty"`       // uniform lower bound; clamps the others
+	MaxMs        int    `yaml:"max_ms,omitempty" json:"max_ms,omitempty"`       // uniform upper bound; clamps the others
+}
+
+// sample draws one delay from the configured distribution, or 0 if the fault
+// isn't configured at all.
+func (l LatencyFault) sample() time.Duration {
+	if l.MeanMs == 0 && l.MinMs == 0 && l.MaxMs == 0 && l.StddevMs == 0 {
+		return 0
+	}
+
+	var ms float64
+	switch l.Distribution {
+	case "uniform":
+		lo, hi := float64(l.MinMs), float64(l.MaxMs)
+		if hi <= lo {
+			hi = lo
+		}
+		ms = lo + faultRNG.Float64()*(hi-lo)
+	case "normal":
+		ms = float64(l.MeanMs) + faultRNG.NormFloat64()*float64(l.StddevMs)
+	case "lognormal":
+		mean := float64(l.MeanMs)
+		if mean < 1 {
+			mean = 1
+		}
+		// Stddev is interpreted as a coefficient of variation of the mean,
+		// so existing mean_ms/stddev_ms configs carry over from "normal".
+		sigma := float64(l.StddevMs) / mean
+		ms = math.Exp(math.Log(mean) + faultRNG.NormFloat64()*sigma)
+	default: // "fixed" or unset
+		ms = float64(l.MeanMs)
+	}
+
+	if l.MinMs > 0 && ms < float64(l.MinMs) {
+		ms = float64(l.MinMs)
+	}
+	if l.MaxMs > 0 && ms > float64(l.MaxMs) {
+		ms = float64(l.MaxMs)
+	}
+	if ms < 0 {
+		ms = 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// Fault describes network misbehavior to inject around an otherwise normal
+// stub response: added latency, a throttled write rate, and a handful of
+// canned connection failure modes. Shared by HTTPStub and TCPStub so the
+// same vocabulary describes flakiness at either layer.
+type Fault struct {
+	Latency         LatencyFault       `yaml:"latency,omitempty" json:"latency,omitempty"`
+	BandwidthBPS    int                `yaml:"bandwidth_bps,omitempty" json:"bandwidth_bps,omitempty"`       // Token-bucket rate limit on writes, in bytes/sec
+	ResetConnection bool               `yaml:"reset_connection,omitempty" json:"reset_connection,omitempty"` // Close the connection instead of responding
+	HalfWrite       int                `yaml:"half_write,omitempty" json:"half_write,omitempty"`             // Write only this many bytes of the response, then close
+	GarbageResponse int                `yaml:"garbage_response,omitempty" json:"garbage_response,omitempty"` // Replace the response with this many random bytes
+	ChunkedDribble  bool               `yaml:"chunked_dribble,omitempty" json:"chunked_dribble,omitempty"`   // Write the response one byte at a time
+	DribbleDelayMs  int                `yaml:"dribble_delay_ms,omitempty" json:"dribble_delay_ms,omitempty"` // Delay between bytes when ChunkedDribble is set
+	RandomStatus    map[string]float64 `yaml:"random_status,omitempty" json:"random_status,omitempty"`       // HTTP only: status code -> probability, e.g. {"500": 0.1}
+}
+
+// seededRand wraps math/rand.Rand with a mutex so concurrently handled
+// connections can share one deterministic, --seed-able source.
+type seededRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newSeededRand(seed int64) *seededRand {
+	return &seededRand{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (s *seededRand) reseed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rng = rand.New(rand.NewSource(seed))
+}
+
+func (s *seededRand) Float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64()
+}
+
+func (s *seededRand) NormFloat64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.NormFloat64()
+}
+
+func (s *seededRand) bytes(n int) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := make([]byte, n)
+	s.rng.Read(b)
+	return b
+}
+
+// faultRNG is the process-wide source behind all fault sampling (latency
+// distributions, random_status, garbage_response). seedFaults re-points it
+// at a deterministic source when --seed is passed, so flaky-network
+// scenarios can be reproduced byte-for-byte in a test run.
+var faultRNG = newSeededRand(time.Now().UnixNano())
+
+// seedFaults re-seeds faultRNG, called once from main() when --seed is set.
+func seedFaults(seed int64) {
+	faultRNG.reseed(seed)
+}
+
+// pickStatus weights normalStatus against f.RandomStatus, iterating keys in
+// sorted order so the outcome only depends on the seed, not map iteration.
+func (f Fault) pickStatus(normalStatus int) int {
+	if len(f.RandomStatus) == 0 {
+		return normalStatus
+	}
+
+	keys := make([]string, 0, len(f.RandomStatus))
+	for k := range f.RandomStatus {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	r := faultRNG.Float64()
+	cumulative := 0.0
+	for _, k := range keys {
+		cumulative += f.RandomStatus[k]
+		if r < cumulative {
+			if code, err := strconv.Atoi(k); err == nil {
+				return code
+			}
+			break
+		}
+	}
+	return normalStatus
+}
+
+// throttledWriter rate-limits Write calls to roughly bps bytes/sec using a
+// simple token bucket refilled every 100ms.
+type throttledWriter struct {
+	w    io.Writer
+	bps  int
+	sent int
+	tick time.Time
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	if t.tick.IsZero() {
+		t.tick = time.Now()
+	}
+	chunkSize := t.bps / 10
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+
+	written := 0
+	for written < len(p) {
+		n := chunkSize
+		if remaining := len(p) - written; n > remaining {
+			n = remaining
+		}
+		if _, err := t.w.Write(p[written : written+n]); err != nil {
+			return written, err
+		}
+		if f, ok := t.w.(http.Flusher); ok {
+			f.Flush()
+		}
+		written += n
+		if written < len(p) {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return written, nil
+}
+
+// dribbleWriter writes one byte at a time with a fixed delay in between,
+// simulating a connection that drip-feeds its response.
+type dribbleWriter struct {
+	w        io.Writer
+	delay    time.Duration
+	wroteAny bool
+}
+
+func (d *dribbleWriter) Write(p []byte) (int, error) {
+	for i, b := range p {
+		if d.wroteAny || i > 0 {
+			time.Sleep(d.delay)
+		}
+		if _, err := d.w.Write([]byte{b}); err != nil {
+			return i, err
+		}
+		if f, ok := d.w.(http.Flusher); ok {
+			f.Flush()
+		}
+		d.wroteAny = true
+	}
+	return len(p), nil
+}
+
+// writer wraps w with whichever throughput fault is configured, in priority
+// order: chunked_dribble, then bandwidth_bps, else w unchanged.
+func (f Fault) writer(w io.Writer) io.Writer {
+	if f.ChunkedDribble {
+		delay := time.Duration(f.DribbleDelayMs) * time.Millisecond
+		return &dribbleWriter{w: w, delay: delay}
+	}
+	if f.BandwidthBPS > 0 {
+		return &throttledWriter{w: w, bps: f.BandwidthBPS}
+	}
+	return w
+}
+
+// errFaultReset and errFaultHalfWrite are returned by writeTCP so
+// callers can log a fault-induced close distinctly from a genuine I/O error.
+var (
+	errFaultReset     = errors.New("connection reset by fault injection")
+	errFaultHalfWrite = errors.New("connection closed after half_write fault")
+)
+
+// writeTCP applies f around a TCP stub's response: it sleeps for the sampled
+// latency, then handles reset_connection/half_write/garbage_response as
+// connection-level failures before falling back to framer.WriteFrame (itself
+// wrapped for bandwidth_bps/chunked_dribble). half_write and garbage_response
+// still go through framer first so length-prefixed/delimited framing headers
+// are present in what gets truncated or corrupted, matching what a real
+// flaky peer on that wire format would produce. Returns nil on a normal
+// write, or the error/fault that ended the connection.
+func (f Fault) writeTCP(conn net.Conn, framer Framer, data []byte) error {
+	if d := f.Latency.sample(); d > 0 {
+		time.Sleep(d)
+	}
+
+	if f.ResetConnection {
+		conn.Close()
+		return errFaultReset
+	}
+
+	if f.GarbageResponse > 0 {
+		return framer.WriteFrame(conn, faultRNG.bytes(f.GarbageResponse))
+	}
+
+	if f.HalfWrite > 0 {
+		var framed bytes.Buffer
+		if err := framer.WriteFrame(&framed, data); err != nil {
+			return err
+		}
+		raw := framed.Bytes()
+		n := f.HalfWrite
+		if n > len(raw) {
+			n = len(raw)
+		}
+		conn.Write(raw[:n])
+		conn.Close()
+		return errFaultHalfWrite
+	}
+
+	return framer.WriteFrame(f.writer(conn), data)
+}
+
+// writeHTTP applies f around an HTTP stub's response: random_status
+// reweights the status code, then reset_connection/half_write hijack the
+// connection to fail below the http.ResponseWriter abstraction, and
+// garbage_response/chunked_dribble/bandwidth_bps shape the body that's
+// actually written.
+func (f Fault) writeHTTP(w http.ResponseWriter, status int, headers map[string]string, body []byte) {
+	if d := f.Latency.sample(); d > 0 {
+		time.Sleep(d)
+	}
+
+	status = f.pickStatus(status)
+
+	if f.ResetConnection || f.HalfWrite > 0 {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			w.WriteHeader(status)
+			return
+		}
+		conn, bufrw, err := hijacker.Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if f.ResetConnection {
+			return // Close without writing anything, approximating a reset.
+		}
+
+		raw := rawHTTPResponse(status, headers, body)
+		n := f.HalfWrite
+		if n > len(raw) {
+			n = len(raw)
+		}
+		bufrw.Write(raw[:n])
+		bufrw.Flush()
+		return
+	}
+
+	if f.GarbageResponse > 0 {
+		body = faultRNG.bytes(f.GarbageResponse)
+	}
+
+	for key, value := range headers {
+		w.Header().Set(key, value)
+	}
+	w.WriteHeader(status)
+	f.writer(w).Write(body)
+}
+
+// rawHTTPResponse renders a complete HTTP/1.1 response (status line, headers,
+// Content-Length, body) as bytes, for faults that write directly to a
+// hijacked connection below the http.ResponseWriter abstraction.
+func rawHTTPResponse(status int, headers map[string]string, body []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	for key, value := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(body))
+	buf.Write(body)
+	return buf.Bytes()
+}
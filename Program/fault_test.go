@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLatencyFaultSampleUnconfiguredIsZero(t *testing.T) {
+	var l LatencyFault
+	if d := l.sample(); d != 0 {
+		t.Errorf("expected an unconfigured LatencyFault to sample 0, got %v", d)
+	}
+}
+
+func TestLatencyFaultSampleUniformClampsToRange(t *testing.T) {
+	l := LatencyFault{Distribution: "uniform", MinMs: 50, MaxMs: 100}
+	for i := 0; i < 20; i++ {
+		d := l.sample()
+		if d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("expected sample within [50ms, 100ms], got %v", d)
+		}
+	}
+}
+
+func TestLatencyFaultSampleNormalClampsToMinMax(t *testing.T) {
+	l := LatencyFault{Distribution: "normal", MeanMs: 1000, StddevMs: 10000, MinMs: 100, MaxMs: 200}
+	for i := 0; i < 20; i++ {
+		d := l.sample()
+		if d < 100*time.Millisecond || d > 200*time.Millisecond {
+			t.Fatalf("expected a wide-stddev normal sample to clamp into [100ms, 200ms], got %v", d)
+		}
+	}
+}
+
+func TestLatencyFaultSampleFixed(t *testing.T) {
+	l := LatencyFault{MeanMs: 42}
+	if got, want := l.sample(), 42*time.Millisecond; got != want {
+		t.Errorf("expected fixed latency %v, got %v", want, got)
+	}
+}
+
+func TestPickStatusProbabilityOneForcesStatus(t *testing.T) {
+	seedFaults(1)
+	f := Fault{RandomStatus: map[string]float64{"500": 1.0}}
+	if got := f.pickStatus(200); got != 500 {
+		t.Errorf("expected random_status to force 500 with probability 1.0, got %d", got)
+	}
+}
+
+func TestPickStatusFallsBackWhenUnconfigured(t *testing.T) {
+	var f Fault
+	if got := f.pickStatus(204); got != 204 {
+		t.Errorf("expected pickStatus to pass through normalStatus when unconfigured, got %d", got)
+	}
+}
+
+func TestThrottledWriterWritesFullPayload(t *testing.T) {
+	var buf bytes.Buffer
+	tw := &throttledWriter{w: &buf, bps: 1000}
+
+	payload := []byte("hello world")
+	n, err := tw.Write(payload)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if n != len(payload) {
+		t.Errorf("expected %d bytes written, got %d", len(payload), n)
+	}
+	if buf.String() != string(payload) {
+		t.Errorf("expected the full payload to arrive at the destination, got %q", buf.String())
+	}
+}
+
+func TestDribbleWriterWritesOneByteAtATime(t *testing.T) {
+	var buf bytes.Buffer
+	dw := &dribbleWriter{w: &buf, delay: 0}
+
+	payload := []byte("abc")
+	n, err := dw.Write(payload)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if n != len(payload) {
+		t.Errorf("expected %d bytes written, got %d", len(payload), n)
+	}
+	if buf.String() != "abc" {
+		t.Errorf("expected the payload to arrive intact, got %q", buf.String())
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Framer decodes/encodes a single protocol data unit (PDU) off a TCP stream.
+// TCPStubServer.handleConnection loops over ReadFrame until the connection
+// closes, instead of treating a single 4096-byte Read as "the request".
+type Framer interface {
+	ReadFrame(r *bufio.Reader) ([]byte, error)
+	WriteFrame(w io.Writer, data []byte) error
+}
+
+// maxFrameBytes bounds any single decoded frame. length_prefixed reads this
+// many bytes straight off an attacker-controlled header, so without a cap a
+// bogus length forces a multi-gigabyte allocation attempt per frame.
+const maxFrameBytes = 16 << 20 // 16 MiB
+
+// newFramer builds the Framer configured by a TCPStub's Framing block,
+// defaulting to the legacy "raw" behavior when unset.
+func newFramer(cfg TCPFraming) (Framer, error) {
+	switch cfg.Type {
+	case "", "raw":
+		return rawFramer{}, nil
+	case "length_prefixed":
+		prefixBytes := cfg.PrefixBytes
+		if prefixBytes == 0 {
+			prefixBytes = 4
+		}
+		if prefixBytes != 1 && prefixBytes != 2 && prefixBytes != 4 {
+			return nil, fmt.Errorf("length_prefixed: prefix_bytes must be 1, 2 or 4, got %d", prefixBytes)
+		}
+		return lengthPrefixedFramer{
+			prefixBytes:   prefixBytes,
+			littleEndian:  cfg.PrefixEndian == "little",
+			includeHeader: cfg.IncludeHeaderInLength,
+		}, nil
+	case "delimited":
+		delim := cfg.Delimiter
+		if delim == "" {
+			delim = "\n"
+		}
+		return delimitedFramer{delim: []byte(delim)}, nil
+	case "fixed":
+		if cfg.RecordSize <= 0 {
+			return nil, fmt.Errorf("fixed: record_size must be > 0")
+		}
+		return fixedFramer{size: cfg.RecordSize}, nil
+	case "http1_line":
+		// CRLF-terminated lines, as used by HTTP/1.x headers and SMTP-style
+		// command protocols. NOT the same framing as NMDC (see "nmdc" below).
+		return delimitedFramer{delim: []byte("\r\n")}, nil
+	case "nmdc":
+		// NMDC (Neo-Modern Direct Connect) frames every command as
+		// "$Cmd params|", i.e. pipe-terminated rather than CRLF-terminated.
+		return delimitedFramer{delim: []byte("|")}, nil
+	default:
+		return nil, fmt.Errorf("unknown framing type %q", cfg.Type)
+	}
+}
+
+// rawFramer preserves the original behavior: a single opportunistic Read is
+// treated as one frame. Kept as the default so existing configs keep working.
+type rawFramer struct{}
+
+func (rawFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	buffer := make([]byte, 4096)
+	n, err := r.Read(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return buffer[:n], nil
+}
+
+func (rawFramer) WriteFrame(w io.Writer, data []byte) error {
+	_, err := w.Write(data)
+	return err
+}
+
+// lengthPrefixedFramer reads a fixed-size length header followed by that
+// many bytes of payload.
+type lengthPrefixedFramer struct {
+	prefixBytes   int
+	littleEndian  bool
+	includeHeader bool
+}
+
+func (f lengthPrefixedFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, f.prefixBytes)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := f.decodeLength(header)
+	if f.includeHeader {
+		length -= f.prefixBytes
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("length_prefixed: negative payload length %d", length)
+	}
+	if length > maxFrameBytes {
+		return nil, fmt.Errorf("length_prefixed: payload length %d exceeds max frame size %d", length, maxFrameBytes)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (f lengthPrefixedFramer) decodeLength(header []byte) int {
+	switch f.prefixBytes {
+	case 1:
+		return int(header[0])
+	case 2:
+		if f.littleEndian {
+			return int(binary.LittleEndian.Uint16(header))
+		}
+		return int(binary.BigEndian.Uint16(header))
+	default:
+		if f.littleEndian {
+			return int(binary.LittleEndian.Uint32(header))
+		}
+		return int(binary.BigEndian.Uint32(header))
+	}
+}
+
+func (f lengthPrefixedFramer) WriteFrame(w io.Writer, data []byte) error {
+	length := len(data)
+	if f.includeHeader {
+		length += f.prefixBytes
+	}
+
+	header := make([]byte, f.prefixBytes)
+	switch f.prefixBytes {
+	case 1:
+		header[0] = byte(length)
+	case 2:
+		if f.littleEndian {
+			binary.LittleEndian.PutUint16(header, uint16(length))
+		} else {
+			binary.BigEndian.PutUint16(header, uint16(length))
+		}
+	default:
+		if f.littleEndian {
+			binary.LittleEndian.PutUint32(header, uint32(length))
+		} else {
+			binary.BigEndian.PutUint32(header, uint32(length))
+		}
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// delimitedFramer reads up to and including a byte/string delimiter (e.g.
+// "\n" or "\r\n\r\n"), returning the frame without the delimiter.
+type delimitedFramer struct {
+	delim []byte
+}
+
+func (f delimitedFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	// Scan byte by byte for the terminator (single- or multi-byte) rather
+	// than bufio.Reader.ReadBytes, which would grow its buffer without
+	// bound against a peer that never sends the delimiter.
+	var frame []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		frame = append(frame, b)
+		if len(frame) > maxFrameBytes {
+			return nil, fmt.Errorf("delimited: frame exceeds max frame size %d before delimiter %q was seen", maxFrameBytes, f.delim)
+		}
+		if len(frame) >= len(f.delim) && bytesEqual(frame[len(frame)-len(f.delim):], f.delim) {
+			return frame[:len(frame)-len(f.delim)], nil
+		}
+	}
+}
+
+func (f delimitedFramer) WriteFrame(w io.Writer, data []byte) error {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.Write(f.delim)
+	return err
+}
+
+func bytesEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fixedFramer reads a constant-size record.
+type fixedFramer struct {
+	size int
+}
+
+func (f fixedFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, f.size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (f fixedFramer) WriteFrame(w io.Writer, data []byte) error {
+	_, err := w.Write(data)
+	return err
+}
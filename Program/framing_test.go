@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestNewFramerNMDCSplitsOnPipe(t *testing.T) {
+	framer, err := newFramer(TCPFraming{Type: "nmdc"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := bufio.NewReader(strings.NewReader("$MyNick Foo|$Lock bar|"))
+
+	first, err := framer.ReadFrame(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading first frame: %v", err)
+	}
+	if string(first) != "$MyNick Foo" {
+		t.Errorf("expected first frame %q, got %q", "$MyNick Foo", first)
+	}
+
+	second, err := framer.ReadFrame(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading second frame: %v", err)
+	}
+	if string(second) != "$Lock bar" {
+		t.Errorf("expected second frame %q, got %q", "$Lock bar", second)
+	}
+}
+
+func TestNewFramerNMDCWriteFrame(t *testing.T) {
+	framer, err := newFramer(TCPFraming{Type: "nmdc"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := framer.WriteFrame(&buf, []byte("$Lock foo")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != "$Lock foo|" {
+		t.Errorf("expected pipe-terminated output, got %q", buf.String())
+	}
+}
+
+func TestNewFramerHTTP1LineUsesCRLF(t *testing.T) {
+	framer, err := newFramer(TCPFraming{Type: "http1_line"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := bufio.NewReader(strings.NewReader("HELO example.com\r\n"))
+	frame, err := framer.ReadFrame(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(frame) != "HELO example.com" {
+		t.Errorf("expected CRLF-stripped line, got %q", frame)
+	}
+}
+
+func TestLengthPrefixedFramerReadsPayload(t *testing.T) {
+	framer, err := newFramer(TCPFraming{Type: "length_prefixed", PrefixBytes: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := bufio.NewReader(bytes.NewReader([]byte{0x00, 0x05, 'h', 'e', 'l', 'l', 'o'}))
+	frame, err := framer.ReadFrame(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(frame) != "hello" {
+		t.Errorf("expected payload %q, got %q", "hello", frame)
+	}
+}
+
+func TestLengthPrefixedFramerRejectsLengthAboveMaxFrameBytes(t *testing.T) {
+	framer, err := newFramer(TCPFraming{Type: "length_prefixed", PrefixBytes: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, 0xFFFFFFFF)
+	r := bufio.NewReader(bytes.NewReader(header))
+
+	if _, err := framer.ReadFrame(r); err == nil {
+		t.Fatalf("expected an oversized length prefix to be rejected before allocating")
+	}
+}
+
+func TestDelimitedFramerRejectsFrameAboveMaxFrameBytesWithoutDelimiter(t *testing.T) {
+	framer, err := newFramer(TCPFraming{Type: "delimited", Delimiter: "\n"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := bufio.NewReader(bytes.NewReader(bytes.Repeat([]byte{'x'}, maxFrameBytes+1)))
+	if _, err := framer.ReadFrame(r); err == nil {
+		t.Fatalf("expected a frame with no delimiter to be rejected once it exceeds the max frame size")
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GRPCStub describes how to answer a single RPC, matched generically against
+// a FileDescriptorSet loaded at startup rather than hand-written server code.
+type GRPCStub struct {
+	Name          string                 `yaml:"name" json:"name"`
+	FullMethod    string                 `yaml:"full_method" json:"full_method"`                       // e.g. "/pkg.Service/Method"
+	RequestJSON   map[string]interface{} `yaml:"request_json,omitempty" json:"request_json,omitempty"` // Predicate; same operators as HTTPStub.BodyJSON
+	ResponseJSON  string                 `yaml:"response_json" json:"response_json"`                   // One response message, or a JSON array for server streams
+	StatusCode    int                    `yaml:"status_code,omitempty" json:"status_code,omitempty"`   // google.golang.org/grpc/codes.Code; 0 = OK
+	StatusMessage string                 `yaml:"status_message,omitempty" json:"status_message,omitempty"`
+	Trailers      map[string]string      `yaml:"trailers,omitempty" json:"trailers,omitempty"`
+	DelayMs       int                    `yaml:"delay_ms,omitempty" json:"delay_ms,omitempty"` // Per-message delay for server streams
+}
+
+// GRPCStubServer answers arbitrary RPCs described by a user-supplied
+// .protoset (a FileDescriptorSet produced by `protoc --descriptor_set_out`),
+// dispatched generically through grpc.Server's UnknownServiceHandler so no
+// generated Go server code is needed per proto. stubs is guarded by mu, the
+// same way HTTPStubServer/TCPStubServer protect theirs, so it can be
+// mutated live through the admin API while Serve is handling RPCs.
+type GRPCStubServer struct {
+	mu      sync.RWMutex
+	stubs   []GRPCStub
+	methods map[string]protoreflect.MethodDescriptor
+}
+
+// NewGRPCStubServer loads protosetPath and indexes every method declared by
+// every service in it, keyed by its fully-qualified gRPC method name
+// ("/package.Service/Method").
+func NewGRPCStubServer(protosetPath string) (*GRPCStubServer, error) {
+	data, err := os.ReadFile(protosetPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protoset %s: %w", protosetPath, err)
+	}
+
+	var fdSet descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fdSet); err != nil {
+		return nil, fmt.Errorf("failed to parse protoset %s: %w", protosetPath, err)
+	}
+
+	var files *protoregistry.Files
+	files, err = protodesc.NewFiles(&fdSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build file registry from %s: %w", protosetPath, err)
+	}
+
+	methods := map[string]protoreflect.MethodDescriptor{}
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			svc := services.Get(i)
+			svcMethods := svc.Methods()
+			for j := 0; j < svcMethods.Len(); j++ {
+				m := svcMethods.Get(j)
+				fullMethod := fmt.Sprintf("/%s/%s", svc.FullName(), m.Name())
+				methods[fullMethod] = m
+			}
+		}
+		return true
+	})
+
+	log.Printf("[gRPC] Loaded %d method(s) from %s", len(methods), protosetPath)
+	return &GRPCStubServer{methods: methods}, nil
+}
+
+// AddStub adds a gRPC stub programmatically.
+func (s *GRPCStubServer) AddStub(stub GRPCStub) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs = append(s.stubs, stub)
+}
+
+// SetStubs replaces the entire gRPC stub set, e.g. on POST /admin/reload.
+func (s *GRPCStubServer) SetStubs(stubs []GRPCStub) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs = stubs
+}
+
+// RemoveStub removes the gRPC stub with the given name, reporting whether one was found.
+func (s *GRPCStubServer) RemoveStub(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, stub := range s.stubs {
+		if stub.Name == name {
+			s.stubs = append(s.stubs[:i], s.stubs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Stubs returns a snapshot of the currently configured gRPC stubs.
+func (s *GRPCStubServer) Stubs() []GRPCStub {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]GRPCStub(nil), s.stubs...)
+}
+
+// Serve starts a grpc.Server on the given address, routing every RPC to
+// handleUnknownService regardless of which service it names.
+func (s *GRPCStubServer) Serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := grpc.NewServer(grpc.UnknownServiceHandler(s.handleUnknownService))
+	log.Printf("[gRPC] Stub server listening on %s", addr)
+	return server.Serve(listener)
+}
+
+// handleUnknownService is invoked for every RPC, since no service was
+// statically registered; it looks up the method descriptor, matches a stub,
+// and plays back its scripted response(s).
+func (s *GRPCStubServer) handleUnknownService(srv interface{}, stream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "could not determine method from stream")
+	}
+
+	methodDesc, ok := s.methods[fullMethod]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "no proto descriptor loaded for method %s", fullMethod)
+	}
+
+	request := dynamicpb.NewMessage(methodDesc.Input())
+	if err := stream.RecvMsg(request); err != nil {
+		return status.Errorf(codes.Internal, "failed to read request: %v", err)
+	}
+
+	requestJSON, err := protojson.Marshal(request)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to marshal request for matching: %v", err)
+	}
+	var requestMap map[string]interface{}
+	if err := json.Unmarshal(requestJSON, &requestMap); err != nil {
+		return status.Errorf(codes.Internal, "failed to decode request JSON: %v", err)
+	}
+
+	stub, ok := s.matchStub(fullMethod, requestMap)
+	if !ok {
+		return status.Errorf(codes.NotFound, "no gRPC stub matched %s", fullMethod)
+	}
+	log.Printf("[gRPC] Matched stub: %s (%s)", stub.Name, fullMethod)
+
+	for key, value := range stub.Trailers {
+		stream.SetTrailer(metadata.Pairs(key, value))
+	}
+
+	for i, responseJSON := range splitResponses(stub.ResponseJSON) {
+		response := dynamicpb.NewMessage(methodDesc.Output())
+		if err := protojson.Unmarshal([]byte(responseJSON), response); err != nil {
+			return status.Errorf(codes.Internal, "invalid response_json for stub %s: %v", stub.Name, err)
+		}
+		if i > 0 && stub.DelayMs > 0 {
+			time.Sleep(time.Duration(stub.DelayMs) * time.Millisecond)
+		}
+		if err := stream.SendMsg(response); err != nil {
+			return status.Errorf(codes.Internal, "failed to send response: %v", err)
+		}
+	}
+
+	if stub.StatusCode != 0 {
+		return status.Error(codes.Code(stub.StatusCode), stub.StatusMessage)
+	}
+	return nil
+}
+
+// matchStub finds the first stub whose FullMethod and RequestJSON predicate
+// match the incoming request, reusing the same operator predicates as
+// HTTPStub.BodyJSON ($gt, $in, $regex, ...).
+func (s *GRPCStubServer) matchStub(fullMethod string, requestMap map[string]interface{}) (GRPCStub, bool) {
+	for _, stub := range s.Stubs() {
+		if stub.FullMethod != fullMethod {
+			continue
+		}
+		matched := true
+		for key, expected := range stub.RequestJSON {
+			if !jsonFieldMatches(requestMap, key, expected) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return stub, true
+		}
+	}
+	return GRPCStub{}, false
+}
+
+// splitResponses turns a stub's ResponseJSON into one or more JSON messages:
+// a bare object is a single unary/server-stream message, a JSON array scripts
+// a multi-message server stream.
+func splitResponses(responseJSON string) []string {
+	trimmed := strings.TrimSpace(responseJSON)
+	if !strings.HasPrefix(trimmed, "[") {
+		return []string{trimmed}
+	}
+
+	var rawMessages []json.RawMessage
+	if err := json.Unmarshal([]byte(trimmed), &rawMessages); err != nil {
+		return []string{trimmed}
+	}
+	messages := make([]string, len(rawMessages))
+	for i, raw := range rawMessages {
+		messages[i] = string(raw)
+	}
+	return messages
+}
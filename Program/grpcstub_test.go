@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGRPCStubServerConcurrentAddAndStubs(t *testing.T) {
+	server := &GRPCStubServer{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			server.AddStub(GRPCStub{Name: "stub", FullMethod: "/pkg.Svc/Method"})
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(server.Stubs()); got != 20 {
+		t.Errorf("expected 20 stubs after concurrent AddStub, got %d", got)
+	}
+}
+
+func TestGRPCStubServerRemoveStub(t *testing.T) {
+	server := &GRPCStubServer{}
+	server.AddStub(GRPCStub{Name: "a"})
+	server.AddStub(GRPCStub{Name: "b"})
+
+	if !server.RemoveStub("a") {
+		t.Fatalf("expected to remove stub %q", "a")
+	}
+	if server.RemoveStub("a") {
+		t.Errorf("expected a second removal of %q to report not found", "a")
+	}
+
+	stubs := server.Stubs()
+	if len(stubs) != 1 || stubs[0].Name != "b" {
+		t.Errorf("expected only stub %q to remain, got %+v", "b", stubs)
+	}
+}
+
+func TestGRPCStubServerMatchStub(t *testing.T) {
+	server := &GRPCStubServer{}
+	server.AddStub(GRPCStub{
+		Name:        "get-adult",
+		FullMethod:  "/pkg.Svc/Get",
+		RequestJSON: map[string]interface{}{"age": map[string]interface{}{"$gte": float64(18)}},
+	})
+	server.AddStub(GRPCStub{Name: "get-any", FullMethod: "/pkg.Svc/Get"})
+
+	stub, ok := server.matchStub("/pkg.Svc/Get", map[string]interface{}{"age": float64(21)})
+	if !ok {
+		t.Fatalf("expected a match")
+	}
+	if stub.Name != "get-adult" {
+		t.Errorf("expected the predicate-matching stub to win, got %q", stub.Name)
+	}
+
+	stub, ok = server.matchStub("/pkg.Svc/Get", map[string]interface{}{"age": float64(10)})
+	if !ok {
+		t.Fatalf("expected the fallback stub with no predicate to match")
+	}
+	if stub.Name != "get-any" {
+		t.Errorf("expected the unconditional stub to match when the predicate fails, got %q", stub.Name)
+	}
+
+	if _, ok := server.matchStub("/pkg.Svc/Other", nil); ok {
+		t.Errorf("expected no match for an unconfigured method")
+	}
+}
+
+func TestSplitResponsesUnaryMessage(t *testing.T) {
+	messages := splitResponses(`  {"id": 1}  `)
+	if len(messages) != 1 || messages[0] != `{"id": 1}` {
+		t.Errorf("expected a single trimmed message, got %+v", messages)
+	}
+}
+
+func TestSplitResponsesJSONArraySplitsIntoMultipleMessages(t *testing.T) {
+	messages := splitResponses(`[{"id": 1}, {"id": 2}, {"id": 3}]`)
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d: %+v", len(messages), messages)
+	}
+	if messages[0] != `{"id": 1}` || messages[1] != `{"id": 2}` || messages[2] != `{"id": 3}` {
+		t.Errorf("expected each array element as its own message, got %+v", messages)
+	}
+}
+
+func TestSplitResponsesInvalidArrayFallsBackToWholeInput(t *testing.T) {
+	messages := splitResponses(`[not valid json`)
+	if len(messages) != 1 || messages[0] != `[not valid json` {
+		t.Errorf("expected the malformed input returned verbatim as a single message, got %+v", messages)
+	}
+}
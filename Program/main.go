@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"flag"
@@ -11,7 +12,11 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -21,17 +26,32 @@ import (
 type StubConfig struct {
 	HTTPStubs []HTTPStub `yaml:"http_stubs" json:"http_stubs"`
 	TCPStubs  []TCPStub  `yaml:"tcp_stubs" json:"tcp_stubs"`
+	WSStubs   []WSStub   `yaml:"ws_stubs" json:"ws_stubs"`
+	GRPCStubs []GRPCStub `yaml:"grpc_stubs" json:"grpc_stubs"`
 }
 
 // HTTPStub represents a single HTTP stub endpoint
 type HTTPStub struct {
 	Name         string                 `yaml:"name" json:"name"`
 	Method       string                 `yaml:"method" json:"method"`
-	Path         string                 `yaml:"path" json:"path"`
-	Headers      map[string]string      `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Path         string                 `yaml:"path" json:"path"`                                       // Exact path, or a mux-style pattern like /users/{id} or /files/*
+	PathRegex    string                 `yaml:"path_regex,omitempty" json:"path_regex,omitempty"`       // Takes precedence over Path when set; named groups become path vars
+	QueryParams  map[string]string      `yaml:"query_params,omitempty" json:"query_params,omitempty"`   // Values may use the "~=" prefix to match as regex
+	Headers      map[string]string      `yaml:"headers,omitempty" json:"headers,omitempty"`             // Values may use the "~=" prefix to match as regex
 	BodyContains string                 `yaml:"body_contains,omitempty" json:"body_contains,omitempty"` // Match if body contains this string
-	BodyJSON     map[string]interface{} `yaml:"body_json,omitempty" json:"body_json,omitempty"`         // Match specific JSON fields
+	BodyJSON     map[string]interface{} `yaml:"body_json,omitempty" json:"body_json,omitempty"`         // Match specific JSON fields; values may be operator objects ($gt, $lt, $in, $ne, $regex)
 	Response     HTTPResponse           `yaml:"response" json:"response"`
+
+	Mode           string            `yaml:"mode,omitempty" json:"mode,omitempty"` // "" (canned response, default), "proxy", or "record"
+	UpstreamURL    string            `yaml:"upstream_url,omitempty" json:"upstream_url,omitempty"`
+	UpstreamTLS    UpstreamTLSConfig `yaml:"upstream_tls,omitempty" json:"upstream_tls,omitempty"`
+	RewriteHeaders map[string]string `yaml:"rewrite_headers,omitempty" json:"rewrite_headers,omitempty"` // Applied to the request before it's forwarded upstream
+
+	Scenario      string `yaml:"scenario,omitempty" json:"scenario,omitempty"`
+	RequiredState string `yaml:"required_state,omitempty" json:"required_state,omitempty"` // Defaults to "Started"
+	NewState      string `yaml:"new_state,omitempty" json:"new_state,omitempty"`
+
+	Fault Fault `yaml:"fault,omitempty" json:"fault,omitempty"` // Latency/bandwidth/connection faults to inject around the response
 }
 
 // HTTPResponse defines the HTTP stub response
@@ -57,41 +77,143 @@ type TCPStub struct {
 	MaxLength          int    `yaml:"max_length,omitempty" json:"max_length,omitempty"`
 	ErrorResponse      string `yaml:"error_response,omitempty" json:"error_response,omitempty"` // Response on validation failure
 	ErrorResponseHex   string `yaml:"error_response_hex,omitempty" json:"error_response_hex,omitempty"`
+
+	Framing    TCPFraming `yaml:"framing,omitempty" json:"framing,omitempty"`         // How to split the byte stream into PDUs; defaults to "raw"
+	FrameRules []WSRule   `yaml:"frame_rules,omitempty" json:"frame_rules,omitempty"` // Per-frame match/reply rules; same shape as a WSStub's Rules
+
+	Mode         string            `yaml:"mode,omitempty" json:"mode,omitempty"` // "" (canned response, default), "proxy", or "record"
+	UpstreamAddr string            `yaml:"upstream_addr,omitempty" json:"upstream_addr,omitempty"`
+	UpstreamTLS  UpstreamTLSConfig `yaml:"upstream_tls,omitempty" json:"upstream_tls,omitempty"`
+
+	Scenario      string `yaml:"scenario,omitempty" json:"scenario,omitempty"`
+	RequiredState string `yaml:"required_state,omitempty" json:"required_state,omitempty"` // Defaults to "Started"
+	NewState      string `yaml:"new_state,omitempty" json:"new_state,omitempty"`
+
+	Fault Fault `yaml:"fault,omitempty" json:"fault,omitempty"` // Latency/bandwidth/connection faults to inject around the response
+}
+
+// TCPFraming selects and configures the Framer used to split a TCPStub's
+// byte stream into individual PDUs.
+type TCPFraming struct {
+	Type                  string `yaml:"type" json:"type"`                                       // raw (default) | length_prefixed | delimited | fixed | http1_line | nmdc
+	PrefixBytes           int    `yaml:"prefix_bytes,omitempty" json:"prefix_bytes,omitempty"`   // length_prefixed: 1, 2 or 4 (default 4)
+	PrefixEndian          string `yaml:"prefix_endian,omitempty" json:"prefix_endian,omitempty"` // length_prefixed: "big" (default) or "little"
+	IncludeHeaderInLength bool   `yaml:"include_header_in_length,omitempty" json:"include_header_in_length,omitempty"`
+	Delimiter             string `yaml:"delimiter,omitempty" json:"delimiter,omitempty"`     // delimited: e.g. "\n" or "\r\n\r\n" (default "\n")
+	RecordSize            int    `yaml:"record_size,omitempty" json:"record_size,omitempty"` // fixed: size of each record in bytes
 }
 
 // HTTPStubServer manages HTTP stub endpoints
 type HTTPStubServer struct {
-	stubs []HTTPStub
+	mu      sync.RWMutex
+	stubs   []HTTPStub
+	wsStubs []WSStub
 }
 
 // NewHTTPStubServer creates a new HTTP stub server
 func NewHTTPStubServer() *HTTPStubServer {
 	return &HTTPStubServer{
-		stubs: []HTTPStub{},
+		stubs:   []HTTPStub{},
+		wsStubs: []WSStub{},
 	}
 }
 
 // AddStub adds an HTTP stub programmatically
 func (s *HTTPStubServer) AddStub(stub HTTPStub) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.stubs = append(s.stubs, stub)
 }
 
-// matchRequest checks if a request matches a stub
-func (s *HTTPStubServer) matchRequest(r *http.Request, stub HTTPStub) bool {
+// AddWSStub adds a WebSocket stub programmatically
+func (s *HTTPStubServer) AddWSStub(stub WSStub) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wsStubs = append(s.wsStubs, stub)
+}
+
+// Stubs returns a snapshot of the currently configured HTTP stubs.
+func (s *HTTPStubServer) Stubs() []HTTPStub {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]HTTPStub(nil), s.stubs...)
+}
+
+// SetStubs replaces the HTTP stub list wholesale, e.g. for hot-reload or the admin API.
+func (s *HTTPStubServer) SetStubs(stubs []HTTPStub) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs = stubs
+}
+
+// RemoveStub removes the HTTP stub with the given name, reporting whether one was found.
+func (s *HTTPStubServer) RemoveStub(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, stub := range s.stubs {
+		if stub.Name == name {
+			s.stubs = append(s.stubs[:i], s.stubs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// WSStubs returns a snapshot of the currently configured WS stubs.
+func (s *HTTPStubServer) WSStubs() []WSStub {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]WSStub(nil), s.wsStubs...)
+}
+
+// SetWSStubs replaces the WS stub list wholesale, e.g. for hot-reload or the admin API.
+func (s *HTTPStubServer) SetWSStubs(stubs []WSStub) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wsStubs = stubs
+}
+
+// RemoveWSStub removes the WS stub with the given name, reporting whether one was found.
+func (s *HTTPStubServer) RemoveWSStub(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, stub := range s.wsStubs {
+		if stub.Name == name {
+			s.wsStubs = append(s.wsStubs[:i], s.wsStubs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// matchRequest checks if a request matches a stub, returning the captured
+// path/query variables when it does (nil vars on a non-match).
+func (s *HTTPStubServer) matchRequest(r *http.Request, stub HTTPStub) (bool, *matchVars) {
 	// Match method
 	if !strings.EqualFold(stub.Method, r.Method) {
-		return false
+		return false, nil
 	}
 
-	// Match path (exact match for now, can be extended to patterns)
-	if stub.Path != r.URL.Path {
-		return false
+	// Match path, either a mux-style pattern/exact path or an explicit regex
+	pathVars, ok := matchPath(stub, r.URL.Path)
+	if !ok {
+		return false, nil
+	}
+
+	// Match query params if specified
+	queryVars := map[string]string{}
+	for key, matcher := range stub.QueryParams {
+		value := r.URL.Query().Get(key)
+		if !valueMatches(matcher, value) {
+			return false, nil
+		}
+		queryVars[key] = value
 	}
 
 	// Match headers if specified
-	for key, value := range stub.Headers {
-		if r.Header.Get(key) != value {
-			return false
+	for key, matcher := range stub.Headers {
+		if !valueMatches(matcher, r.Header.Get(key)) {
+			return false, nil
 		}
 	}
 
@@ -100,7 +222,7 @@ func (s *HTTPStubServer) matchRequest(r *http.Request, stub HTTPStub) bool {
 		// Read body
 		bodyBytes, err := io.ReadAll(r.Body)
 		if err != nil {
-			return false
+			return false, nil
 		}
 		// Restore body for later reads
 		r.Body = io.NopCloser(strings.NewReader(string(bodyBytes)))
@@ -109,29 +231,154 @@ func (s *HTTPStubServer) matchRequest(r *http.Request, stub HTTPStub) bool {
 
 		// Check if body contains string
 		if stub.BodyContains != "" && !strings.Contains(bodyStr, stub.BodyContains) {
-			return false
+			return false, nil
 		}
 
 		// Check JSON fields match
 		if len(stub.BodyJSON) > 0 {
 			var requestJSON map[string]interface{}
 			if err := json.Unmarshal(bodyBytes, &requestJSON); err != nil {
-				return false
+				return false, nil
 			}
 
 			// Check if all specified fields match
 			for key, expectedValue := range stub.BodyJSON {
 				if !jsonFieldMatches(requestJSON, key, expectedValue) {
-					return false
+					return false, nil
 				}
 			}
 		}
 	}
 
-	return true
+	if !scenarioGate(stub.Scenario, stub.RequiredState, stub.NewState) {
+		return false, nil
+	}
+
+	return true, &matchVars{Path: pathVars, Query: queryVars}
+}
+
+// matchVars holds the path/query variables captured while matching a stub,
+// made available to the response body template.
+type matchVars struct {
+	Path  map[string]string
+	Query map[string]string
 }
 
-// jsonFieldMatches checks if a JSON field matches expected value (supports nested paths with dots)
+// pathPatternCache avoids recompiling the same mux-style pattern on every
+// request. ServeHTTP calls matchPath concurrently (once per inbound
+// connection), so the cache is guarded by pathPatternCacheMu.
+var (
+	pathPatternCacheMu sync.RWMutex
+	pathPatternCache   = map[string]*regexp.Regexp{}
+)
+
+// matchPath matches a request path against a stub's Path/PathRegex and
+// returns any captured named variables.
+func matchPath(stub HTTPStub, requestPath string) (map[string]string, bool) {
+	if stub.PathRegex != "" {
+		re, err := regexp.Compile(stub.PathRegex)
+		if err != nil {
+			log.Printf("[HTTP] Invalid path_regex %q for stub %q: %v", stub.PathRegex, stub.Name, err)
+			return nil, false
+		}
+		return namedGroupMatch(re, requestPath)
+	}
+
+	// Fast path: plain exact match, no pattern syntax involved
+	if !strings.ContainsAny(stub.Path, "{*") {
+		if stub.Path != requestPath {
+			return nil, false
+		}
+		return map[string]string{}, true
+	}
+
+	re := cachedPathPattern(stub.Path)
+	return namedGroupMatch(re, requestPath)
+}
+
+// cachedPathPattern returns the compiled pattern for path, compiling and
+// caching it on first use.
+func cachedPathPattern(path string) *regexp.Regexp {
+	pathPatternCacheMu.RLock()
+	re, ok := pathPatternCache[path]
+	pathPatternCacheMu.RUnlock()
+	if ok {
+		return re
+	}
+
+	pathPatternCacheMu.Lock()
+	defer pathPatternCacheMu.Unlock()
+	if re, ok := pathPatternCache[path]; ok {
+		return re
+	}
+	re = compilePathPattern(path)
+	pathPatternCache[path] = re
+	return re
+}
+
+// pathTokenRe matches the pattern syntax compilePathPattern understands:
+// a {name} path param or a bare * wildcard.
+var pathTokenRe = regexp.MustCompile(`\{(\w+)\}|\*`)
+
+// compilePathPattern turns a gorilla/mux style pattern such as /users/{id}
+// or /files/* into an anchored regex with named capture groups. Literal
+// segments are quoted individually so QuoteMeta can't escape the {}/*
+// syntax before it's recognized.
+func compilePathPattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+
+	last := 0
+	for _, loc := range pathTokenRe.FindAllStringSubmatchIndex(pattern, -1) {
+		start, end := loc[0], loc[1]
+		b.WriteString(regexp.QuoteMeta(pattern[last:start]))
+		if pattern[start:end] == "*" {
+			b.WriteString("(?P<wildcard>.*)")
+		} else {
+			name := pattern[loc[2]:loc[3]]
+			b.WriteString(fmt.Sprintf("(?P<%s>[^/]+)", name))
+		}
+		last = end
+	}
+	b.WriteString(regexp.QuoteMeta(pattern[last:]))
+
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// namedGroupMatch runs re against s and returns the named capture groups.
+func namedGroupMatch(re *regexp.Regexp, s string) (map[string]string, bool) {
+	match := re.FindStringSubmatch(s)
+	if match == nil {
+		return nil, false
+	}
+	vars := map[string]string{}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			vars[name] = match[i]
+		}
+	}
+	return vars, true
+}
+
+// valueMatches compares an actual value against a matcher string. A matcher
+// prefixed with "~=" is treated as a regular expression, otherwise it's an
+// exact match.
+func valueMatches(matcher, actual string) bool {
+	if rx, ok := strings.CutPrefix(matcher, "~="); ok {
+		re, err := regexp.Compile(rx)
+		if err != nil {
+			log.Printf("[HTTP] Invalid regex matcher %q: %v", rx, err)
+			return false
+		}
+		return re.MatchString(actual)
+	}
+	return matcher == actual
+}
+
+// jsonFieldMatches checks if a JSON field matches expected value (supports nested paths with dots).
+// expectedValue may be a plain scalar for an exact match, or an operator object
+// such as {"$gt": 5}, {"$lt": 5}, {"$in": [...]}, {"$ne": ...} or {"$regex": "..."}.
 func jsonFieldMatches(data map[string]interface{}, path string, expectedValue interface{}) bool {
 	keys := strings.Split(path, ".")
 
@@ -144,66 +391,245 @@ func jsonFieldMatches(data map[string]interface{}, path string, expectedValue in
 		}
 	}
 
+	if ops, ok := expectedValue.(map[string]interface{}); ok {
+		return jsonOperatorMatches(current, ops)
+	}
+
 	// Compare values
 	return fmt.Sprintf("%v", current) == fmt.Sprintf("%v", expectedValue)
 }
 
+// jsonOperatorMatches evaluates the operator predicates supported in BodyJSON.
+func jsonOperatorMatches(actual interface{}, ops map[string]interface{}) bool {
+	for op, operand := range ops {
+		switch op {
+		case "$gt", "$gte", "$lt", "$lte":
+			a, aOk := toFloat(actual)
+			b, bOk := toFloat(operand)
+			if !aOk || !bOk {
+				return false
+			}
+			switch op {
+			case "$gt":
+				if !(a > b) {
+					return false
+				}
+			case "$gte":
+				if !(a >= b) {
+					return false
+				}
+			case "$lt":
+				if !(a < b) {
+					return false
+				}
+			case "$lte":
+				if !(a <= b) {
+					return false
+				}
+			}
+		case "$ne":
+			if fmt.Sprintf("%v", actual) == fmt.Sprintf("%v", operand) {
+				return false
+			}
+		case "$in":
+			options, ok := operand.([]interface{})
+			if !ok {
+				return false
+			}
+			found := false
+			for _, option := range options {
+				if fmt.Sprintf("%v", actual) == fmt.Sprintf("%v", option) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		case "$regex":
+			pattern, ok := operand.(string)
+			if !ok {
+				return false
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Printf("[HTTP] Invalid $regex %q: %v", pattern, err)
+				return false
+			}
+			if !re.MatchString(fmt.Sprintf("%v", actual)) {
+				return false
+			}
+		default:
+			log.Printf("[HTTP] Unknown BodyJSON operator %q", op)
+			return false
+		}
+	}
+	return true
+}
+
+// toFloat converts JSON-decoded scalars (float64, string) to a float64 for comparison.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// renderBody interpolates path/query variables into a stub's response body
+// using Go text/template (e.g. `{"id": "{{ .Path.id }}"}`).
+func renderBody(body string, vars *matchVars) string {
+	if vars == nil || !strings.Contains(body, "{{") {
+		return body
+	}
+	tmpl, err := template.New("response").Parse(body)
+	if err != nil {
+		log.Printf("[HTTP] Invalid response body template: %v", err)
+		return body
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		log.Printf("[HTTP] Failed to render response body template: %v", err)
+		return body
+	}
+	return buf.String()
+}
+
 // ServeHTTP handles incoming HTTP requests
 func (s *HTTPStubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[HTTP %s] %s %s", time.Now().Format("15:04:05"), r.Method, r.URL.Path)
 
-	for _, stub := range s.stubs {
-		if s.matchRequest(r, stub) {
+	if wsStub, ok := s.matchWSStub(r.URL.Path); ok {
+		s.handleWebSocket(w, r, wsStub)
+		return
+	}
+
+	// Capture the body once up front (matchRequest only reads it when a
+	// stub actually has body matchers) so every log entry below, matched or
+	// not, can report what the caller sent.
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	body := string(bodyBytes)
+
+	for _, stub := range s.Stubs() {
+		if matched, vars := s.matchRequest(r, stub); matched {
 			log.Printf("[HTTP] Matched stub: %s", stub.Name)
 
-			if stub.Response.Delay > 0 {
-				time.Sleep(time.Duration(stub.Response.Delay) * time.Millisecond)
+			if stub.Mode == "proxy" || stub.Mode == "record" {
+				requestLog.Add(RequestLogEntry{Time: time.Now(), Protocol: "http", Summary: r.Method + " " + r.URL.Path, Matched: true, StubName: stub.Name, Body: body})
+				proxyHTTPRequest(w, r, stub)
+				return
 			}
 
-			for key, value := range stub.Response.Headers {
-				w.Header().Set(key, value)
+			if stub.Response.Delay > 0 {
+				time.Sleep(time.Duration(stub.Response.Delay) * time.Millisecond)
 			}
 
-			w.WriteHeader(stub.Response.Status)
-			w.Write([]byte(stub.Response.Body))
+			stub.Fault.writeHTTP(w, stub.Response.Status, stub.Response.Headers, []byte(renderBody(stub.Response.Body, vars)))
+			requestLog.Add(RequestLogEntry{Time: time.Now(), Protocol: "http", Summary: r.Method + " " + r.URL.Path, Matched: true, StubName: stub.Name, Body: body})
 			return
 		}
 	}
 
 	log.Printf("[HTTP] No stub matched for %s %s", r.Method, r.URL.Path)
+	requestLog.Add(RequestLogEntry{Time: time.Now(), Protocol: "http", Summary: r.Method + " " + r.URL.Path, Matched: false, Body: body})
 	w.WriteHeader(http.StatusNotFound)
 	w.Write([]byte(`{"error": "No stub matched"}`))
 }
 
 // TCPStubServer handles TCP connections
 type TCPStubServer struct {
-	stubs map[int]*TCPStub
+	mu        sync.RWMutex
+	stubs     map[int]*TCPStub
+	listeners map[int]net.Listener
+	started   bool
 }
 
 // NewTCPStubServer creates a new TCP stub server
 func NewTCPStubServer() *TCPStubServer {
 	return &TCPStubServer{
-		stubs: make(map[int]*TCPStub),
+		stubs:     make(map[int]*TCPStub),
+		listeners: make(map[int]net.Listener),
 	}
 }
 
-// AddStub adds a TCP stub
+// AddStub adds a TCP stub. If the server is already running (e.g. this call
+// comes from the admin API), its listener is started immediately.
 func (s *TCPStubServer) AddStub(stub TCPStub) {
+	s.mu.Lock()
 	s.stubs[stub.Port] = &stub
+	started := s.started
+	s.mu.Unlock()
+
+	if started {
+		go s.startListener(stub.Port)
+	}
 }
 
-// Start starts all TCP stub listeners
-func (s *TCPStubServer) Start() error {
+// RemoveStub stops and removes the TCP stub listening on the given port.
+func (s *TCPStubServer) RemoveStub(port int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.stubs[port]; !ok {
+		return false
+	}
+	delete(s.stubs, port)
+	if listener, ok := s.listeners[port]; ok {
+		listener.Close()
+		delete(s.listeners, port)
+	}
+	return true
+}
+
+// Stubs returns a snapshot of the currently configured TCP stubs.
+func (s *TCPStubServer) Stubs() map[int]TCPStub {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := make(map[int]TCPStub, len(s.stubs))
 	for port, stub := range s.stubs {
-		go func(p int, st *TCPStub) {
-			if err := s.listenTCP(p, st); err != nil {
-				log.Printf("[TCP] Stub %s error: %v", st.Name, err)
-			}
-		}(port, stub)
+		snapshot[port] = *stub
+	}
+	return snapshot
+}
+
+// Start starts all currently configured TCP stub listeners.
+func (s *TCPStubServer) Start() error {
+	s.mu.Lock()
+	s.started = true
+	ports := make([]int, 0, len(s.stubs))
+	for port := range s.stubs {
+		ports = append(ports, port)
+	}
+	s.mu.Unlock()
+
+	for _, port := range ports {
+		go s.startListener(port)
 	}
 	return nil
 }
 
+// startListener looks up the stub configured for port and listens on it,
+// retrying is left to the caller (e.g. the admin API can AddStub again).
+func (s *TCPStubServer) startListener(port int) {
+	s.mu.RLock()
+	stub, ok := s.stubs[port]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	if err := s.listenTCP(port, stub); err != nil {
+		log.Printf("[TCP] Stub %s error: %v", stub.Name, err)
+	}
+}
+
 // listenTCP starts a TCP listener for a specific stub
 func (s *TCPStubServer) listenTCP(port int, stub *TCPStub) error {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
@@ -212,6 +638,10 @@ func (s *TCPStubServer) listenTCP(port int, stub *TCPStub) error {
 	}
 	defer listener.Close()
 
+	s.mu.Lock()
+	s.listeners[port] = listener
+	s.mu.Unlock()
+
 	log.Printf("[TCP] Stub '%s' listening on port %d", stub.Name, port)
 	if stub.ValidateRequest {
 		log.Printf("[TCP] Stub '%s' has request validation enabled", stub.Name)
@@ -220,8 +650,9 @@ func (s *TCPStubServer) listenTCP(port int, stub *TCPStub) error {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Printf("[TCP] Error accepting connection: %v", err)
-			continue
+			// A closed listener (e.g. via RemoveStub) ends the loop instead of spinning on errors.
+			log.Printf("[TCP] Listener for stub '%s' stopped: %v", stub.Name, err)
+			return nil
 		}
 
 		go s.handleConnection(conn, stub)
@@ -257,80 +688,136 @@ func min(a, b int) int {
 	return b
 }
 
-// handleConnection handles a single TCP connection
+// handleConnection handles a single TCP connection, decoding frames with the
+// stub's configured Framer rather than treating a single 4096-byte Read as
+// "the request".
 func (s *TCPStubServer) handleConnection(conn net.Conn, stub *TCPStub) {
+	if !scenarioGate(stub.Scenario, stub.RequiredState, stub.NewState) {
+		log.Printf("[TCP:%s] Scenario %q not in required state %q; closing connection", stub.Name, stub.Scenario, stub.RequiredState)
+		conn.Close()
+		return
+	}
+
+	if stub.Mode == "proxy" || stub.Mode == "record" {
+		requestLog.Add(RequestLogEntry{Time: time.Now(), Protocol: "tcp", Summary: fmt.Sprintf("tcp:%d", stub.Port), Matched: true, StubName: stub.Name})
+		s.proxyTCPConnection(conn, stub)
+		return
+	}
+
 	defer conn.Close()
 
 	clientAddr := conn.RemoteAddr().String()
 	log.Printf("[TCP:%s %s] Connection from %s", stub.Name, time.Now().Format("15:04:05"), clientAddr)
 
-	// Read incoming data
 	reader := bufio.NewReader(conn)
 
-	// For binary protocols, read all available data or up to a buffer size
-	buffer := make([]byte, 4096)
-	n, err := reader.Read(buffer)
+	framer, err := newFramer(stub.Framing)
 	if err != nil {
-		log.Printf("[TCP:%s] Error reading data: %v", stub.Name, err)
+		log.Printf("[TCP:%s] Invalid framing config: %v", stub.Name, err)
 		return
 	}
 
-	data := buffer[:n]
-	hexData := hex.EncodeToString(data)
-	log.Printf("[TCP:%s] Received %d bytes: %s", stub.Name, n, hexData)
-
-	// Validate request if enabled
-	if stub.ValidateRequest {
-		valid, reason := s.validateRequest(data, stub)
-		if !valid {
-			log.Printf("[TCP:%s] ❌ Validation failed: %s", stub.Name, reason)
-			log.Printf("[TCP:%s] Simulating timeout (no response sent)", stub.Name)
-			// Just close the connection without sending anything - simulates timeout
+	first := true
+	for {
+		frame, err := framer.ReadFrame(reader)
+		if err != nil {
+			if !first {
+				break // Connection closed after at least one frame; nothing more to do
+			}
+			log.Printf("[TCP:%s] Error reading data: %v", stub.Name, err)
 			return
 		}
-		log.Printf("[TCP:%s] ✅ Validation passed", stub.Name)
-	}
 
-	// Apply delay if specified
-	if stub.Delay > 0 {
-		time.Sleep(time.Duration(stub.Delay) * time.Millisecond)
-	}
+		hexData := hex.EncodeToString(frame)
+		log.Printf("[TCP:%s] Received %d bytes: %s", stub.Name, len(frame), hexData)
+		requestLog.Add(RequestLogEntry{Time: time.Now(), Protocol: "tcp", Summary: fmt.Sprintf("tcp:%d", stub.Port), Matched: true, StubName: stub.Name, Body: hexData})
+
+		// Validate request if enabled (applies to the first frame only)
+		if first && stub.ValidateRequest {
+			valid, reason := s.validateRequest(frame, stub)
+			if !valid {
+				log.Printf("[TCP:%s] ❌ Validation failed: %s", stub.Name, reason)
+				log.Printf("[TCP:%s] Simulating timeout (no response sent)", stub.Name)
+				// Just close the connection without sending anything - simulates timeout
+				return
+			}
+			log.Printf("[TCP:%s] ✅ Validation passed", stub.Name)
+		}
 
-	// Send stub response
-	var responseData []byte
-	if stub.ResponseHex != "" {
-		// Decode hex response
-		responseData, err = hex.DecodeString(stub.ResponseHex)
+		if len(stub.FrameRules) > 0 {
+			if !s.replyToFrame(conn, framer, stub, frame) {
+				return
+			}
+			first = false
+			continue
+		}
+
+		// Legacy behavior: every frame gets the stub's same canned response.
+		if stub.Delay > 0 {
+			time.Sleep(time.Duration(stub.Delay) * time.Millisecond)
+		}
+
+		responseData, err := legacyResponse(stub)
 		if err != nil {
 			log.Printf("[TCP:%s] Error decoding response hex: %v", stub.Name, err)
 			return
 		}
-	} else {
-		responseData = []byte(stub.ResponseMessage)
+
+		if err := stub.Fault.writeTCP(conn, framer, responseData); err != nil {
+			log.Printf("[TCP:%s] Error writing response: %v", stub.Name, err)
+			return
+		}
+		log.Printf("[TCP:%s] Sent %d bytes response to %s", stub.Name, len(responseData), clientAddr)
+
+		if stub.CloseAfter {
+			return
+		}
+		first = false
 	}
+}
 
-	_, err = conn.Write(responseData)
-	if err != nil {
-		log.Printf("[TCP:%s] Error writing response: %v", stub.Name, err)
-		return
+// replyToFrame matches a decoded frame against the stub's FrameRules and
+// writes the scripted reply. Returns false if the connection should be closed.
+func (s *TCPStubServer) replyToFrame(conn net.Conn, framer Framer, stub *TCPStub, frame []byte) bool {
+	rule, ok := matchWSRule(stub.FrameRules, frame)
+	if !ok {
+		log.Printf("[TCP:%s] No frame rule matched: %s", stub.Name, hex.EncodeToString(frame))
+		return true
 	}
 
-	log.Printf("[TCP:%s] Sent %d bytes response to %s", stub.Name, len(responseData), clientAddr)
+	if rule.Then.DelayMs > 0 {
+		time.Sleep(time.Duration(rule.Then.DelayMs) * time.Millisecond)
+	}
 
-	// Optionally keep connection open or close it
-	if !stub.CloseAfter {
-		// Keep connection open for more data
-		for {
-			n, err := reader.Read(buffer)
-			if err != nil {
-				break
-			}
-			data := buffer[:n]
-			hexData := hex.EncodeToString(data)
-			log.Printf("[TCP:%s] Received: %s", stub.Name, hexData)
-			conn.Write(responseData)
+	var reply []byte
+	var err error
+	if rule.Then.ReplyHex != "" {
+		reply, err = hex.DecodeString(rule.Then.ReplyHex)
+	} else {
+		reply = []byte(rule.Then.ReplyText)
+	}
+	if err != nil {
+		log.Printf("[TCP:%s] Invalid frame rule reply: %v", stub.Name, err)
+		return false
+	}
+
+	if len(reply) > 0 {
+		if err := stub.Fault.writeTCP(conn, framer, reply); err != nil {
+			log.Printf("[TCP:%s] Error writing reply: %v", stub.Name, err)
+			return false
 		}
 	}
+
+	return !rule.Then.Close
+}
+
+// legacyResponse builds the single canned response used when a TCPStub has
+// no FrameRules configured.
+func legacyResponse(stub *TCPStub) ([]byte, error) {
+	if stub.ResponseHex != "" {
+		return hex.DecodeString(stub.ResponseHex)
+	}
+	return []byte(stub.ResponseMessage), nil
 }
 
 // LoadConfig loads stubs from a YAML or JSON file
@@ -356,7 +843,7 @@ func LoadConfig(filename string) (*StubConfig, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	log.Printf("Parsed config: %d HTTP stubs, %d TCP stubs", len(config.HTTPStubs), len(config.TCPStubs))
+	log.Printf("Parsed config: %d HTTP stubs, %d TCP stubs, %d WS stubs", len(config.HTTPStubs), len(config.TCPStubs), len(config.WSStubs))
 
 	return &config, nil
 }
@@ -364,11 +851,30 @@ func LoadConfig(filename string) (*StubConfig, error) {
 func main() {
 	configFile := flag.String("config", "", "Path to config file (YAML or JSON)")
 	httpPort := flag.Int("http-port", 8080, "HTTP port to listen on")
+	adminPort := flag.Int("admin-port", 0, "Port for the admin API (stub CRUD, reload, request log); disabled if 0")
+	recordOut := flag.String("record-out", "", "File to append recorded proxy exchanges to (required for mode: record stubs)")
+	grpcPort := flag.Int("grpc-port", 0, "Port for the gRPC stub server; disabled if 0")
+	grpcProtoset := flag.String("grpc-protoset", "", "Path to a .protoset (FileDescriptorSet) describing the gRPC services to stub")
+	seed := flag.Int64("seed", 0, "Seed for fault injection (latency distributions, random_status, garbage_response); 0 uses a time-based seed")
 	flag.Parse()
 
+	recordOutPath = *recordOut
+	if *seed != 0 {
+		seedFaults(*seed)
+	}
+
 	httpServer := NewHTTPStubServer()
 	tcpServer := NewTCPStubServer()
 
+	var grpcServer *GRPCStubServer
+	if *grpcProtoset != "" {
+		var err error
+		grpcServer, err = NewGRPCStubServer(*grpcProtoset)
+		if err != nil {
+			log.Fatalf("Error loading gRPC protoset: %v", err)
+		}
+	}
+
 	if *configFile != "" {
 		config, err := LoadConfig(*configFile)
 		if err != nil {
@@ -384,6 +890,21 @@ func main() {
 			tcpServer.AddStub(stub)
 		}
 		log.Printf("Loaded %d TCP stub(s) from %s", len(config.TCPStubs), *configFile)
+
+		for _, stub := range config.WSStubs {
+			httpServer.AddWSStub(stub)
+		}
+		log.Printf("Loaded %d WS stub(s) from %s", len(config.WSStubs), *configFile)
+
+		if len(config.GRPCStubs) > 0 {
+			if grpcServer == nil {
+				log.Fatalf("Config declares %d gRPC stub(s) but --grpc-protoset was not provided", len(config.GRPCStubs))
+			}
+			for _, stub := range config.GRPCStubs {
+				grpcServer.AddStub(stub)
+			}
+			log.Printf("Loaded %d gRPC stub(s) from %s", len(config.GRPCStubs), *configFile)
+		}
 	} else {
 		log.Println("No config file provided, using hardcoded stubs")
 
@@ -403,17 +924,39 @@ func main() {
 	}
 
 	// Start TCP servers
-	if len(tcpServer.stubs) > 0 {
-		log.Printf("Starting %d TCP stub server(s)...", len(tcpServer.stubs))
+	if len(tcpServer.Stubs()) > 0 {
+		log.Printf("Starting %d TCP stub server(s)...", len(tcpServer.Stubs()))
 		if err := tcpServer.Start(); err != nil {
 			log.Fatalf("Failed to start TCP servers: %v", err)
 		}
 	}
 
+	// Start the gRPC stub server, if configured
+	if grpcServer != nil && *grpcPort > 0 {
+		grpcAddr := fmt.Sprintf(":%d", *grpcPort)
+		go func() {
+			if err := grpcServer.Serve(grpcAddr); err != nil {
+				log.Fatalf("gRPC stub server failed: %v", err)
+			}
+		}()
+	}
+
+	// Start the admin API, if requested
+	if *adminPort > 0 {
+		admin := NewAdminServer(httpServer, tcpServer, grpcServer, *configFile)
+		adminAddr := fmt.Sprintf(":%d", *adminPort)
+		go func() {
+			log.Printf("Starting admin API on %s", adminAddr)
+			if err := http.ListenAndServe(adminAddr, admin.Handler()); err != nil {
+				log.Fatalf("Admin API failed: %v", err)
+			}
+		}()
+	}
+
 	// Start HTTP server
 	httpAddr := fmt.Sprintf(":%d", *httpPort)
 	log.Printf("Starting HTTP stub server on %s", httpAddr)
-	log.Printf("Loaded %d HTTP stub(s) and %d TCP stub(s)", len(httpServer.stubs), len(tcpServer.stubs))
+	log.Printf("Loaded %d HTTP stub(s), %d TCP stub(s), %d WS stub(s)", len(httpServer.Stubs()), len(tcpServer.Stubs()), len(httpServer.WSStubs()))
 	log.Println("Server ready to accept requests...")
 
 	if err := http.ListenAndServe(httpAddr, httpServer); err != nil {
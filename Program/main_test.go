@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestMatchPathNamedParam(t *testing.T) {
+	stub := HTTPStub{Path: "/users/{id}"}
+
+	vars, ok := matchPath(stub, "/users/42")
+	if !ok {
+		t.Fatalf("expected /users/{id} to match /users/42")
+	}
+	if vars["id"] != "42" {
+		t.Errorf("expected id=42, got %q", vars["id"])
+	}
+
+	if _, ok := matchPath(stub, "/users/42/posts"); ok {
+		t.Errorf("expected /users/{id} not to match /users/42/posts")
+	}
+}
+
+func TestMatchPathWildcard(t *testing.T) {
+	stub := HTTPStub{Path: "/files/*"}
+
+	vars, ok := matchPath(stub, "/files/a/b.txt")
+	if !ok {
+		t.Fatalf("expected /files/* to match /files/a/b.txt")
+	}
+	if vars["wildcard"] != "a/b.txt" {
+		t.Errorf("expected wildcard=a/b.txt, got %q", vars["wildcard"])
+	}
+}
+
+func TestMatchPathConcurrentDistinctPatterns(t *testing.T) {
+	pathPatternCache = map[string]*regexp.Regexp{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stub := HTTPStub{Path: fmt.Sprintf("/r%d/{id}", i)}
+			if _, ok := matchPath(stub, fmt.Sprintf("/r%d/42", i)); !ok {
+				t.Errorf("expected stub %d to match", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMatchPathExact(t *testing.T) {
+	stub := HTTPStub{Path: "/health"}
+
+	if _, ok := matchPath(stub, "/health"); !ok {
+		t.Errorf("expected exact path match")
+	}
+	if _, ok := matchPath(stub, "/healthz"); ok {
+		t.Errorf("expected exact path not to match a longer path")
+	}
+}
+
+func TestValueMatchesExactAndRegex(t *testing.T) {
+	if !valueMatches("Bearer abc", "Bearer abc") {
+		t.Errorf("expected exact matcher to match identical value")
+	}
+	if valueMatches("Bearer abc", "Bearer xyz") {
+		t.Errorf("expected exact matcher not to match a different value")
+	}
+	if !valueMatches("~=^Bearer .+", "Bearer abc123") {
+		t.Errorf("expected ~= matcher to match via regex")
+	}
+	if valueMatches("~=^Bearer .+", "Basic abc123") {
+		t.Errorf("expected ~= matcher not to match a non-conforming value")
+	}
+	if valueMatches("~=(", "anything") {
+		t.Errorf("expected an invalid regex matcher to fail closed")
+	}
+}
+
+func TestJSONFieldMatchesOperators(t *testing.T) {
+	data := map[string]interface{}{
+		"age":  float64(30),
+		"role": "admin",
+		"nested": map[string]interface{}{
+			"score": float64(7),
+		},
+	}
+
+	cases := []struct {
+		name     string
+		path     string
+		expected interface{}
+		want     bool
+	}{
+		{"exact match", "role", "admin", true},
+		{"exact mismatch", "role", "guest", false},
+		{"gt true", "age", map[string]interface{}{"$gt": float64(18)}, true},
+		{"gt false", "age", map[string]interface{}{"$gt": float64(99)}, false},
+		{"lte true", "age", map[string]interface{}{"$lte": float64(30)}, true},
+		{"ne true", "role", map[string]interface{}{"$ne": "guest"}, true},
+		{"ne false", "role", map[string]interface{}{"$ne": "admin"}, false},
+		{"in true", "role", map[string]interface{}{"$in": []interface{}{"admin", "owner"}}, true},
+		{"in false", "role", map[string]interface{}{"$in": []interface{}{"guest"}}, false},
+		{"regex true", "role", map[string]interface{}{"$regex": "^adm"}, true},
+		{"regex false", "role", map[string]interface{}{"$regex": "^own"}, false},
+		{"nested path", "nested.score", map[string]interface{}{"$gte": float64(7)}, true},
+		{"unknown operator", "role", map[string]interface{}{"$bogus": "admin"}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := jsonFieldMatches(data, c.path, c.expected); got != c.want {
+				t.Errorf("jsonFieldMatches(%q, %v) = %v, want %v", c.path, c.expected, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRenderBodyInterpolatesPathAndQueryVars(t *testing.T) {
+	vars := &matchVars{
+		Path:  map[string]string{"id": "42"},
+		Query: map[string]string{"verbose": "true"},
+	}
+
+	got := renderBody(`{"id": "{{ .Path.id }}", "verbose": "{{ .Query.verbose }}"}`, vars)
+	want := `{"id": "42", "verbose": "true"}`
+	if got != want {
+		t.Errorf("renderBody() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderBodyWithoutTemplateMarkersIsUnchanged(t *testing.T) {
+	body := `{"static": "value"}`
+	if got := renderBody(body, &matchVars{}); got != body {
+		t.Errorf("renderBody() = %q, want unchanged %q", got, body)
+	}
+	if got := renderBody(body, nil); got != body {
+		t.Errorf("renderBody() with nil vars = %q, want unchanged %q", got, body)
+	}
+}
+
+func TestRenderBodyInvalidTemplateReturnsOriginal(t *testing.T) {
+	body := `{"bad": "{{ .Path.id "}`
+	if got := renderBody(body, &matchVars{Path: map[string]string{"id": "1"}}); got != body {
+		t.Errorf("renderBody() with invalid template = %q, want original %q", got, body)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// recordOutPath is where captured exchanges are appended as new stub
+// entries, set from the --record-out CLI flag.
+var recordOutPath string
+
+// recordOutMu serializes the read-modify-write against recordOutPath, since
+// HTTP and TCP record-mode exchanges are each captured from their own
+// per-request/per-connection goroutine.
+var recordOutMu sync.Mutex
+
+// UpstreamTLSConfig controls how a proxy/record stub connects to its
+// upstream over TLS.
+type UpstreamTLSConfig struct {
+	Enabled            bool   `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty" json:"server_name,omitempty"`
+}
+
+// clientTLSConfig builds a *tls.Config from an UpstreamTLSConfig, or nil
+// when TLS isn't enabled.
+func (c UpstreamTLSConfig) clientTLSConfig() *tls.Config {
+	if !c.Enabled {
+		return nil
+	}
+	return &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		ServerName:         c.ServerName,
+	}
+}
+
+// proxyHTTPRequest forwards r to stub.UpstreamURL, optionally recording the
+// exchange as a new HTTPStub appended to recordOutPath, and writes the
+// upstream's response back to w.
+func proxyHTTPRequest(w http.ResponseWriter, r *http.Request, stub HTTPStub) {
+	upstream, err := url.Parse(stub.UpstreamURL)
+	if err != nil {
+		log.Printf("[HTTP:%s] Invalid upstream_url %q: %v", stub.Name, stub.UpstreamURL, err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("[HTTP:%s] Failed to read request body for proxying: %v", stub.Name, err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	if tlsConfig := stub.UpstreamTLS.clientTLSConfig(); tlsConfig != nil {
+		proxy.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	var captured *HTTPResponse
+	if stub.Mode == "record" {
+		captured = &HTTPResponse{Headers: map[string]string{}}
+	}
+
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalDirector(req)
+		for key, value := range stub.RewriteHeaders {
+			req.Header.Set(key, value)
+		}
+	}
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if captured == nil {
+			return nil
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+		captured.Status = resp.StatusCode
+		captured.Body = string(respBody)
+		for key := range resp.Header {
+			captured.Headers[key] = resp.Header.Get(key)
+		}
+		return nil
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	proxy.ServeHTTP(w, r)
+
+	if captured != nil {
+		recordHTTPStub(stub, r, bodyBytes, *captured)
+	}
+}
+
+// recordHTTPStub appends a newly captured exchange as a plain HTTPStub entry
+// to recordOutPath, so a subsequent run can replay it offline.
+func recordHTTPStub(stub HTTPStub, r *http.Request, requestBody []byte, resp HTTPResponse) {
+	if recordOutPath == "" {
+		log.Printf("[HTTP:%s] Recording requested but --record-out not set; skipping capture", stub.Name)
+		return
+	}
+
+	recorded := HTTPStub{
+		Name:     fmt.Sprintf("%s-recorded-%d", stub.Name, time.Now().UnixNano()),
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Response: resp,
+	}
+	if len(requestBody) > 0 {
+		recorded.BodyContains = string(requestBody)
+	}
+
+	if err := appendRecordedStub(recordOutPath, recorded); err != nil {
+		log.Printf("[HTTP:%s] Failed to write recorded stub: %v", stub.Name, err)
+	}
+}
+
+// appendRecordedStub reads the existing fixture file (if any), appends the
+// new stub, and rewrites it as YAML. Guarded by recordOutMu so concurrent
+// captures don't race on the read-modify-write and drop each other's stub.
+func appendRecordedStub(path string, stub HTTPStub) error {
+	recordOutMu.Lock()
+	defer recordOutMu.Unlock()
+
+	config := StubConfig{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("failed to parse existing %s: %w", path, err)
+		}
+	}
+
+	config.HTTPStubs = append(config.HTTPStubs, stub)
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recorded config: %w", err)
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// proxyTCPConnection bridges an accepted connection directly to stub.UpstreamAddr,
+// optionally recording the first request/response pair as a new TCPStub.
+func (s *TCPStubServer) proxyTCPConnection(conn net.Conn, stub *TCPStub) {
+	defer conn.Close()
+
+	var upstream net.Conn
+	var err error
+	if tlsConfig := stub.UpstreamTLS.clientTLSConfig(); tlsConfig != nil {
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		upstream, err = tls.DialWithDialer(dialer, "tcp", stub.UpstreamAddr, tlsConfig)
+	} else {
+		upstream, err = net.DialTimeout("tcp", stub.UpstreamAddr, 5*time.Second)
+	}
+	if err != nil {
+		log.Printf("[TCP:%s] Failed to connect to upstream %s: %v", stub.Name, stub.UpstreamAddr, err)
+		return
+	}
+	defer upstream.Close()
+
+	log.Printf("[TCP:%s] Proxying %s <-> %s", stub.Name, conn.RemoteAddr(), stub.UpstreamAddr)
+
+	var capturedRequest, capturedResponse []byte
+	recording := stub.Mode == "record"
+
+	done := make(chan struct{}, 2)
+	go copyAndCapture(upstream, conn, &capturedRequest, recording, done)
+	go copyAndCapture(conn, upstream, &capturedResponse, recording, done)
+	<-done
+	<-done
+
+	if recording && len(capturedRequest) > 0 {
+		if err := appendRecordedTCPStub(recordOutPath, stub, capturedRequest, capturedResponse); err != nil {
+			log.Printf("[TCP:%s] Failed to write recorded stub: %v", stub.Name, err)
+		}
+	}
+}
+
+// halfCloser is implemented by *net.TCPConn and *tls.Conn: closing just the
+// write half lets the still-open direction keep relaying instead of tearing
+// down the whole connection as soon as one side goes quiet.
+type halfCloser interface {
+	CloseWrite() error
+}
+
+// copyAndCapture copies from src to dst, optionally stashing the first chunk
+// seen into *capture for recording mode. Once src is exhausted it half-closes
+// dst's write side (if supported) rather than leaving that decision to the
+// caller, so the other direction's copy can still finish delivering whatever
+// it has in flight.
+func copyAndCapture(dst io.Writer, src io.Reader, capture *[]byte, recording bool, done chan<- struct{}) {
+	buffer := make([]byte, 4096)
+	for {
+		n, err := src.Read(buffer)
+		if n > 0 {
+			if recording && len(*capture) == 0 {
+				*capture = append([]byte(nil), buffer[:n]...)
+			}
+			if _, werr := dst.Write(buffer[:n]); werr != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if hc, ok := dst.(halfCloser); ok {
+		hc.CloseWrite()
+	}
+	done <- struct{}{}
+}
+
+// appendRecordedTCPStub appends a newly captured TCP exchange to recordOutPath.
+// Guarded by recordOutMu so concurrent captures don't race on the
+// read-modify-write and drop each other's stub.
+func appendRecordedTCPStub(path string, stub *TCPStub, request, response []byte) error {
+	if path == "" {
+		return fmt.Errorf("--record-out not set")
+	}
+
+	recordOutMu.Lock()
+	defer recordOutMu.Unlock()
+
+	config := StubConfig{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("failed to parse existing %s: %w", path, err)
+		}
+	}
+
+	config.TCPStubs = append(config.TCPStubs, TCPStub{
+		Name:           fmt.Sprintf("%s-recorded-%d", stub.Name, time.Now().UnixNano()),
+		Port:           stub.Port,
+		ResponseHex:    hex.EncodeToString(response),
+		ExpectedPrefix: hex.EncodeToString(request),
+		CloseAfter:     stub.CloseAfter,
+	})
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recorded config: %w", err)
+	}
+	return os.WriteFile(path, out, 0644)
+}
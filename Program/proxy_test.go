@@ -0,0 +1,198 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// collectingWriter is an io.Writer that just remembers everything written to
+// it, for asserting copyAndCapture relayed a whole stream rather than a
+// truncated prefix.
+type collectingWriter struct {
+	data   []byte
+	closed bool
+}
+
+func (w *collectingWriter) Write(p []byte) (int, error) {
+	w.data = append(w.data, p...)
+	return len(p), nil
+}
+
+func (w *collectingWriter) CloseWrite() error {
+	w.closed = true
+	return nil
+}
+
+func TestCopyAndCaptureRelaysFullStreamBeforeDone(t *testing.T) {
+	dst := &collectingWriter{}
+	done := make(chan struct{}, 1)
+	var captured []byte
+
+	copyAndCapture(dst, strings.NewReader("hello world"), &captured, false, done)
+	<-done
+
+	if got := string(dst.data); got != "hello world" {
+		t.Errorf("expected the full source to be relayed, got %q", got)
+	}
+}
+
+func TestCopyAndCaptureHalfClosesDestinationOnEOF(t *testing.T) {
+	dst := &collectingWriter{}
+	done := make(chan struct{}, 1)
+	var captured []byte
+
+	copyAndCapture(dst, strings.NewReader("request"), &captured, true, done)
+	<-done
+
+	if !dst.closed {
+		t.Errorf("expected CloseWrite to be called once the source direction reached EOF")
+	}
+	if string(captured) != "request" {
+		t.Errorf("expected recording mode to capture the first chunk, got %q", captured)
+	}
+}
+
+func TestAppendRecordedTCPStubConcurrentWritesDontDropEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorded.yaml")
+	stub := &TCPStub{Name: "echo"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := appendRecordedTCPStub(path, stub, []byte("req"), []byte("resp")); err != nil {
+				t.Errorf("appendRecordedTCPStub: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading recorded file: %v", err)
+	}
+	var config StubConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("parsing recorded file: %v", err)
+	}
+	if len(config.TCPStubs) != 20 {
+		t.Errorf("expected 20 recorded stubs from concurrent writers, got %d", len(config.TCPStubs))
+	}
+}
+
+func TestProxyHTTPRequestRewritesHeadersAndForwardsBody(t *testing.T) {
+	var gotMethod, gotPath, gotBody, gotHeader string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotHeader = r.Header.Get("X-Rewritten")
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("upstream response"))
+	}))
+	defer upstream.Close()
+
+	stub := HTTPStub{
+		Name:           "proxy-stub",
+		UpstreamURL:    upstream.URL,
+		RewriteHeaders: map[string]string{"X-Rewritten": "injected"},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/users/42", strings.NewReader("request body"))
+	rec := httptest.NewRecorder()
+
+	proxyHTTPRequest(rec, req, stub)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if rec.Body.String() != "upstream response" {
+		t.Errorf("expected the upstream body to be relayed, got %q", rec.Body.String())
+	}
+	if rec.Header().Get("X-Upstream") != "yes" {
+		t.Errorf("expected the upstream response header to be relayed")
+	}
+	if gotMethod != http.MethodPost || gotPath != "/users/42" {
+		t.Errorf("expected the request to be forwarded as-is, got %s %s", gotMethod, gotPath)
+	}
+	if gotBody != "request body" {
+		t.Errorf("expected the request body to be forwarded, got %q", gotBody)
+	}
+	if gotHeader != "injected" {
+		t.Errorf("expected RewriteHeaders to be applied to the outbound request, got %q", gotHeader)
+	}
+}
+
+func TestProxyHTTPRequestRecordModeCapturesExchange(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "recorded.yaml")
+	recordOutPath = path
+	defer func() { recordOutPath = "" }()
+
+	stub := HTTPStub{Name: "record-stub", Mode: "record", UpstreamURL: upstream.URL}
+	req := httptest.NewRequest(http.MethodGet, "/status", strings.NewReader("req-body"))
+	rec := httptest.NewRecorder()
+
+	proxyHTTPRequest(rec, req, stub)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading recorded file: %v", err)
+	}
+	var config StubConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("parsing recorded file: %v", err)
+	}
+	if len(config.HTTPStubs) != 1 {
+		t.Fatalf("expected 1 recorded stub, got %d", len(config.HTTPStubs))
+	}
+	recorded := config.HTTPStubs[0]
+	if recorded.Method != http.MethodGet || recorded.Path != "/status" {
+		t.Errorf("expected the recorded stub to match the original request, got %+v", recorded)
+	}
+	if recorded.BodyContains != "req-body" {
+		t.Errorf("expected the request body to be captured as BodyContains, got %q", recorded.BodyContains)
+	}
+	if recorded.Response.Status != http.StatusOK || recorded.Response.Body != `{"ok": true}` {
+		t.Errorf("expected the upstream response to be captured, got %+v", recorded.Response)
+	}
+}
+
+func TestAppendRecordedStubAppendsToExistingConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorded.yaml")
+
+	if err := appendRecordedStub(path, HTTPStub{Name: "first"}); err != nil {
+		t.Fatalf("appendRecordedStub: %v", err)
+	}
+	if err := appendRecordedStub(path, HTTPStub{Name: "second"}); err != nil {
+		t.Fatalf("appendRecordedStub: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading recorded file: %v", err)
+	}
+	var config StubConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("parsing recorded file: %v", err)
+	}
+	if len(config.HTTPStubs) != 2 || config.HTTPStubs[0].Name != "first" || config.HTTPStubs[1].Name != "second" {
+		t.Errorf("expected both stubs to be appended in order, got %+v", config.HTTPStubs)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import "sync"
+
+// scenarioStarted is the default state every scenario begins in, mirroring
+// WireMock's "Started" convention.
+const scenarioStarted = "Started"
+
+// ScenarioStore is a global in-memory state machine keyed by scenario name.
+// A stub with Scenario set only matches while the named scenario is in
+// RequiredState, and transitions it to NewState on a successful match -
+// modeling flows like "first call to /login moves scenario auth to
+// LoggedIn; subsequent calls to /data only match while in LoggedIn".
+type ScenarioStore struct {
+	mu       sync.Mutex
+	states   map[string]string
+	counters map[string]int
+}
+
+// NewScenarioStore creates an empty scenario store.
+func NewScenarioStore() *ScenarioStore {
+	return &ScenarioStore{
+		states:   map[string]string{},
+		counters: map[string]int{},
+	}
+}
+
+// scenarios is the process-wide scenario store shared by the HTTP, TCP and
+// WS stub servers and reset via the admin API.
+var scenarios = NewScenarioStore()
+
+// State returns the current state of a scenario, defaulting to scenarioStarted.
+func (s *ScenarioStore) State(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.states[name]; ok {
+		return state
+	}
+	return scenarioStarted
+}
+
+// Transition moves a scenario to newState and bumps its match counter.
+func (s *ScenarioStore) Transition(name, newState string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[name] = newState
+	s.counters[name]++
+}
+
+// CheckAndTransition atomically checks whether a scenario is in required
+// and, if so, moves it to newState and bumps its counter - all under one
+// lock, so two concurrent requests against the same scenario can't both
+// observe the old state and both transition it.
+func (s *ScenarioStore) CheckAndTransition(name, required, newState string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[name]
+	if !ok {
+		state = scenarioStarted
+	}
+	if state != required {
+		return false
+	}
+
+	if newState != "" {
+		s.states[name] = newState
+		s.counters[name]++
+	}
+	return true
+}
+
+// Counter returns how many times a scenario has transitioned.
+func (s *ScenarioStore) Counter(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[name]
+}
+
+// Reset puts a scenario back to scenarioStarted and zeroes its counter.
+func (s *ScenarioStore) Reset(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, name)
+	delete(s.counters, name)
+}
+
+// scenarioGate reports whether a stub with the given scenario fields is
+// allowed to match right now, and applies the state transition when it is.
+// Stubs that don't set Scenario always pass.
+func scenarioGate(scenarioName, requiredState, newState string) bool {
+	if scenarioName == "" {
+		return true
+	}
+	required := requiredState
+	if required == "" {
+		required = scenarioStarted
+	}
+	return scenarios.CheckAndTransition(scenarioName, required, newState)
+}
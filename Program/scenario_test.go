@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestScenarioGateTransitionsOnce(t *testing.T) {
+	scenarios = NewScenarioStore()
+	name := "login"
+
+	if !scenarioGate(name, "", "LoggedIn") {
+		t.Fatalf("expected first call to match the default Started state")
+	}
+	if scenarioGate(name, "", "LoggedIn") {
+		t.Errorf("expected a second call against required state Started to no longer match")
+	}
+	if scenarios.Counter(name) != 1 {
+		t.Errorf("expected exactly one transition, got counter=%d", scenarios.Counter(name))
+	}
+}
+
+func TestScenarioGateConcurrentCallsTransitionOnlyOnce(t *testing.T) {
+	scenarios = NewScenarioStore()
+	name := "race"
+
+	var wg sync.WaitGroup
+	var matched int32
+	var mu sync.Mutex
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if scenarioGate(name, "", "Done") {
+				mu.Lock()
+				matched++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if matched != 1 {
+		t.Errorf("expected exactly one of the concurrent callers to match the Started state, got %d", matched)
+	}
+	if scenarios.Counter(name) != 1 {
+		t.Errorf("expected exactly one transition, got counter=%d", scenarios.Counter(name))
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WSStub represents a single WebSocket stub endpoint, matched and served
+// through the same HTTPStubServer that handles the HTTP stubs. It mirrors
+// how the sibling Bridge already speaks gorilla/websocket.
+type WSStub struct {
+	Name        string   `yaml:"name" json:"name"`
+	Path        string   `yaml:"path" json:"path"`
+	Subprotocol string   `yaml:"subprotocol,omitempty" json:"subprotocol,omitempty"`
+	Rules       []WSRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+	Pushes      []WSPush `yaml:"pushes,omitempty" json:"pushes,omitempty"` // Server-initiated messages sent on a timer
+	CloseCode   int      `yaml:"close_code,omitempty" json:"close_code,omitempty"`
+
+	PingIntervalMs int `yaml:"ping_interval_ms,omitempty" json:"ping_interval_ms,omitempty"` // Server sends a ping control frame on this interval; 0 disables
+	PongWaitMs     int `yaml:"pong_wait_ms,omitempty" json:"pong_wait_ms,omitempty"`         // Connection is closed if no pong (client-initiated or in reply to our ping) arrives within this window; 0 disables
+
+	Scenario      string `yaml:"scenario,omitempty" json:"scenario,omitempty"`
+	RequiredState string `yaml:"required_state,omitempty" json:"required_state,omitempty"` // Defaults to "Started"
+	NewState      string `yaml:"new_state,omitempty" json:"new_state,omitempty"`
+}
+
+// WSRule matches an incoming frame and describes how to reply to it.
+type WSRule struct {
+	When WSWhen `yaml:"when" json:"when"`
+	Then WSThen `yaml:"then" json:"then"`
+}
+
+// WSWhen is the set of supported frame matchers. Only one should be set per rule.
+type WSWhen struct {
+	TextEquals   string                 `yaml:"text_equals,omitempty" json:"text_equals,omitempty"`
+	HexEquals    string                 `yaml:"hex_equals,omitempty" json:"hex_equals,omitempty"`
+	JSONContains map[string]interface{} `yaml:"json_contains,omitempty" json:"json_contains,omitempty"`
+}
+
+// WSThen describes the scripted reply for a matched frame.
+type WSThen struct {
+	ReplyText string `yaml:"reply_text,omitempty" json:"reply_text,omitempty"`
+	ReplyHex  string `yaml:"reply_hex,omitempty" json:"reply_hex,omitempty"`
+	DelayMs   int    `yaml:"delay_ms,omitempty" json:"delay_ms,omitempty"`
+	Close     bool   `yaml:"close,omitempty" json:"close,omitempty"`
+}
+
+// WSPush is a message the server sends on its own schedule, independent of
+// any client frame, e.g. to simulate a streaming/chat/signaling push.
+type WSPush struct {
+	IntervalMs int    `yaml:"interval_ms" json:"interval_ms"`
+	Text       string `yaml:"text,omitempty" json:"text,omitempty"`
+	Hex        string `yaml:"hex,omitempty" json:"hex,omitempty"`
+}
+
+// wsUpgrader mirrors the permissive upgrader used by the websocket bridge.
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true // Allow all origins (adjust for production)
+	},
+}
+
+// matchWSStub returns the WS stub configured for the given request path, if any.
+func (s *HTTPStubServer) matchWSStub(path string) (WSStub, bool) {
+	for _, stub := range s.WSStubs() {
+		if stub.Path == path && scenarioGate(stub.Scenario, stub.RequiredState, stub.NewState) {
+			return stub, true
+		}
+	}
+	return WSStub{}, false
+}
+
+// handleWebSocket upgrades the connection and drives the stub's scripted
+// reply rules and timer-based pushes for its lifetime.
+func (s *HTTPStubServer) handleWebSocket(w http.ResponseWriter, r *http.Request, stub WSStub) {
+	var responseHeader http.Header
+	if stub.Subprotocol != "" {
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": {stub.Subprotocol}}
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		log.Printf("[WS:%s] Upgrade failed: %v", stub.Name, err)
+		return
+	}
+	defer conn.Close()
+
+	log.Printf("[WS:%s] Connection from %s", stub.Name, r.RemoteAddr)
+
+	// gorilla/websocket allows at most one concurrent writer; the reply
+	// path below and every runWSPush/runWSPing goroutine share this mutex so
+	// they never call WriteMessage/WriteControl on conn at the same time.
+	var writeMu sync.Mutex
+
+	if stub.PongWaitMs > 0 {
+		pongWait := time.Duration(stub.PongWaitMs) * time.Millisecond
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(pongWait))
+			return nil
+		})
+	}
+
+	stopPushes := make(chan struct{})
+	defer close(stopPushes)
+	for _, push := range stub.Pushes {
+		if push.IntervalMs <= 0 {
+			log.Printf("[WS:%s] Skipping push with non-positive interval_ms: %d", stub.Name, push.IntervalMs)
+			continue
+		}
+		go s.runWSPush(conn, stub, push, stopPushes, &writeMu)
+	}
+	if stub.PingIntervalMs > 0 {
+		go s.runWSPing(conn, stub, stopPushes, &writeMu)
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			log.Printf("[WS:%s] Connection closed: %v", stub.Name, err)
+			return
+		}
+
+		rule, ok := matchWSRule(stub.Rules, message)
+		if !ok {
+			log.Printf("[WS:%s] No rule matched frame: %s", stub.Name, message)
+			continue
+		}
+
+		if rule.Then.DelayMs > 0 {
+			time.Sleep(time.Duration(rule.Then.DelayMs) * time.Millisecond)
+		}
+
+		if err := writeWSReply(conn, &writeMu, rule.Then); err != nil {
+			log.Printf("[WS:%s] Write error: %v", stub.Name, err)
+			return
+		}
+
+		if rule.Then.Close {
+			code := stub.CloseCode
+			if code == 0 {
+				code = websocket.CloseNormalClosure
+			}
+			writeMu.Lock()
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+			writeMu.Unlock()
+			return
+		}
+	}
+}
+
+// runWSPush sends a scripted server-initiated message on a fixed interval
+// until the connection is torn down.
+func (s *HTTPStubServer) runWSPush(conn *websocket.Conn, stub WSStub, push WSPush, stop chan struct{}, writeMu *sync.Mutex) {
+	ticker := time.NewTicker(time.Duration(push.IntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := writeWSReply(conn, writeMu, WSThen{ReplyText: push.Text, ReplyHex: push.Hex}); err != nil {
+				log.Printf("[WS:%s] Push write error: %v", stub.Name, err)
+				return
+			}
+		}
+	}
+}
+
+// runWSPing sends a ping control frame on a fixed interval until the
+// connection is torn down. Pongs received from the client (in reply to
+// these pings or unsolicited) are handled by the PongWaitMs read-deadline
+// reset set up in handleWebSocket.
+func (s *HTTPStubServer) runWSPing(conn *websocket.Conn, stub WSStub, stop chan struct{}, writeMu *sync.Mutex) {
+	ticker := time.NewTicker(time.Duration(stub.PingIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			writeMu.Lock()
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second))
+			writeMu.Unlock()
+			if err != nil {
+				log.Printf("[WS:%s] Ping write error: %v", stub.Name, err)
+				return
+			}
+		}
+	}
+}
+
+// writeWSReply writes a scripted reply as text or binary depending on which
+// field is set, holding writeMu for the duration of the write.
+func writeWSReply(conn *websocket.Conn, writeMu *sync.Mutex, then WSThen) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if then.ReplyHex != "" {
+		data, err := hex.DecodeString(then.ReplyHex)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.BinaryMessage, data)
+	}
+	if then.ReplyText != "" {
+		return conn.WriteMessage(websocket.TextMessage, []byte(then.ReplyText))
+	}
+	return nil
+}
+
+// matchWSRule finds the first rule whose When matcher matches the incoming frame.
+// Shared with TCPStub.FrameRules, which use the same match/reply shape.
+func matchWSRule(rules []WSRule, message []byte) (WSRule, bool) {
+	for _, rule := range rules {
+		when := rule.When
+		switch {
+		case when.TextEquals != "":
+			if string(message) == when.TextEquals {
+				return rule, true
+			}
+		case when.HexEquals != "":
+			if strings.EqualFold(hex.EncodeToString(message), when.HexEquals) {
+				return rule, true
+			}
+		case len(when.JSONContains) > 0:
+			var frame map[string]interface{}
+			if err := json.Unmarshal(message, &frame); err != nil {
+				continue
+			}
+			allMatch := true
+			for key, expected := range when.JSONContains {
+				if !jsonFieldMatches(frame, key, expected) {
+					allMatch = false
+					break
+				}
+			}
+			if allMatch {
+				return rule, true
+			}
+		}
+	}
+	return WSRule{}, false
+}
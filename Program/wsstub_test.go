@@ -0,0 +1,211 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMatchWSRuleTextEquals(t *testing.T) {
+	rules := []WSRule{
+		{When: WSWhen{TextEquals: "ping"}, Then: WSThen{ReplyText: "pong"}},
+	}
+
+	rule, ok := matchWSRule(rules, []byte("ping"))
+	if !ok {
+		t.Fatalf("expected a match")
+	}
+	if rule.Then.ReplyText != "pong" {
+		t.Errorf("expected reply %q, got %q", "pong", rule.Then.ReplyText)
+	}
+
+	if _, ok := matchWSRule(rules, []byte("pong")); ok {
+		t.Errorf("expected no match for unrelated text")
+	}
+}
+
+func TestMatchWSRuleHexEqualsIsCaseInsensitive(t *testing.T) {
+	rules := []WSRule{
+		{When: WSWhen{HexEquals: "DEADBEEF"}, Then: WSThen{ReplyHex: "00"}},
+	}
+
+	rule, ok := matchWSRule(rules, []byte{0xde, 0xad, 0xbe, 0xef})
+	if !ok {
+		t.Fatalf("expected a match")
+	}
+	if rule.Then.ReplyHex != "00" {
+		t.Errorf("expected reply hex %q, got %q", "00", rule.Then.ReplyHex)
+	}
+}
+
+func TestMatchWSRuleJSONContains(t *testing.T) {
+	rules := []WSRule{
+		{
+			When: WSWhen{JSONContains: map[string]interface{}{"type": "subscribe"}},
+			Then: WSThen{ReplyText: "subscribed"},
+		},
+	}
+
+	rule, ok := matchWSRule(rules, []byte(`{"type":"subscribe","channel":"orders"}`))
+	if !ok {
+		t.Fatalf("expected a match")
+	}
+	if rule.Then.ReplyText != "subscribed" {
+		t.Errorf("expected reply %q, got %q", "subscribed", rule.Then.ReplyText)
+	}
+
+	if _, ok := matchWSRule(rules, []byte(`not json`)); ok {
+		t.Errorf("expected no match for invalid JSON")
+	}
+}
+
+func TestWriteWSReplyPrefersHexOverText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var writeMu sync.Mutex
+		if err := writeWSReply(conn, &writeMu, WSThen{ReplyHex: "68690a", ReplyText: "ignored"}); err != nil {
+			t.Errorf("unexpected write error: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + server.URL[len("http"):]
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	msgType, data, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if msgType != websocket.BinaryMessage {
+		t.Errorf("expected a binary message, got type %d", msgType)
+	}
+	if string(data) != "hi\n" {
+		t.Errorf("expected payload %q, got %q", "hi\n", data)
+	}
+}
+
+// TestHandleWebSocketSkipsZeroIntervalPush guards the bug where a WSPush
+// with an omitted/zero interval_ms crashed the server with
+// "non-positive interval for NewTicker" inside runWSPush's bare `go` call.
+func TestHandleWebSocketSkipsZeroIntervalPush(t *testing.T) {
+	server := &HTTPStubServer{}
+	stub := WSStub{
+		Name:   "zero-interval",
+		Path:   "/ws",
+		Pushes: []WSPush{{IntervalMs: 0, Text: "should-not-panic"}},
+	}
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.handleWebSocket(w, r, stub)
+	}))
+	defer httpServer.Close()
+
+	wsURL := "ws" + httpServer.URL[len("http"):]
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	// Give the (absent) push goroutine a moment to have fired if it were
+	// ever started, then confirm the connection is still alive.
+	time.Sleep(20 * time.Millisecond)
+	if err := client.WriteMessage(websocket.TextMessage, []byte("still-here")); err != nil {
+		t.Fatalf("connection did not survive a zero-interval push: %v", err)
+	}
+}
+
+func TestHandleWebSocketSendsPeriodicPings(t *testing.T) {
+	server := &HTTPStubServer{}
+	stub := WSStub{
+		Name:           "pinger",
+		Path:           "/ws",
+		PingIntervalMs: 10,
+	}
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.handleWebSocket(w, r, stub)
+	}))
+	defer httpServer.Close()
+
+	wsURL := "ws" + httpServer.URL[len("http"):]
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	pings := make(chan struct{}, 1)
+	client.SetPingHandler(func(string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return client.WriteControl(websocket.PongMessage, nil, time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pings:
+	case <-time.After(time.Second):
+		t.Fatalf("expected a ping control frame within the configured interval")
+	}
+}
+
+func TestHandleWebSocketClosesConnectionWhenPongIsMissed(t *testing.T) {
+	server := &HTTPStubServer{}
+	stub := WSStub{
+		Name:       "no-pong",
+		Path:       "/ws",
+		PongWaitMs: 20,
+	}
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.handleWebSocket(w, r, stub)
+	}))
+	defer httpServer.Close()
+
+	wsURL := "ws" + httpServer.URL[len("http"):]
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	// Send nothing at all, so the server's PongWaitMs read deadline expires
+	// and handleWebSocket tears down the connection on its own.
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := client.ReadMessage()
+		readErr <- err
+	}()
+
+	select {
+	case err := <-readErr:
+		if err == nil {
+			t.Fatalf("expected the connection to be closed after a missed pong")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the server to close the connection after PongWaitMs elapsed")
+	}
+}